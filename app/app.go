@@ -1,64 +1,222 @@
 package app
 
 import (
+	"flag"
 	"fmt"
+	"io"
 	"os"
+	"strings"
 
-	"lem-in/graph"
 	"lem-in/parser"
-	"lem-in/scheduling"
-	"lem-in/simulation"
-	"lem-in/visualizer"
+	"lem-in/solver"
 )
 
+// options holds the values of every command-line flag. Each command only
+// defines the flags it uses.
+type options struct {
+	input    string
+	output   string
+	gridFile string
+	format   string
+	quiet    bool
+	verbose  bool
+	solver   string
+	strict   bool
+	animate  bool
+	seed     int64
+	rooms    int
+	degree   float64
+	ants     int
+	topology string
+	size     string
+	events   string
+}
+
+// command is one lem-in subcommand.
+type command struct {
+	name    string
+	args    string
+	summary string
+	formats []string
+	setup   func(fs *flag.FlagSet, opts *options)
+	run     func(opts *options, args []string) int
+}
+
+var commands = []*command{
+	{
+		name:    "run",
+		args:    "<input_file>",
+		summary: "Solve the farm and print the ant moves turn by turn.",
+		formats: []string{"text", "subject", "json"},
+		setup: func(fs *flag.FlagSet, opts *options) {
+			fs.StringVar(&opts.gridFile, "grid", "simulation_output.txt",
+				"write the 2D grid visualization to `path` (text format only, empty to skip)")
+			fs.BoolVar(&opts.strict, "strict", false, "shorthand for -format subject")
+			fs.BoolVar(&opts.animate, "animate", false,
+				"play the simulation as a terminal animation instead of printing it")
+			fs.StringVar(&opts.events, "events", "",
+				"read an event script closing and opening rooms and tunnels during the run from `path`")
+			addSolverFlag(fs, opts)
+			addVerbosityFlags(fs, opts)
+		},
+		run: runSolve,
+	},
+	{
+		name:    "lint",
+		args:    "<input_file>",
+		summary: "Report every problem in the farm file and exit non-zero if any is found.",
+		formats: []string{"text"},
+		run:     runLint,
+	},
+	{
+		name:    "verify",
+		args:    "<input_file> <moves_file>",
+		summary: "Check a solution (one line of Lx-room moves per turn) against the farm.",
+		formats: []string{"text"},
+		run:     runVerify,
+	},
+	{
+		name:    "stats",
+		args:    "<input_file>",
+		summary: "Print a summary of the farm and the paths chosen for it.",
+		formats: []string{"text"},
+		setup:   addSolverFlag,
+		run:     runStats,
+	},
+	{
+		name:    "render",
+		args:    "<input_file>",
+		summary: "Render the farm and its simulation (text grid, SVG, HTML replay or Graphviz DOT).",
+		formats: []string{"text", "svg", "html", "dot"},
+		setup:   addSolverFlag,
+		run:     runRender,
+	},
+	{
+		name:    "compare",
+		args:    "<input_file>",
+		summary: "Run every solver on the farm and tabulate turns and runtime.",
+		formats: []string{"text"},
+		run:     runCompare,
+	},
+	{
+		name:    "gen",
+		summary: "Generate a random valid farm in the input text format.",
+		formats: []string{"text"},
+		setup:   addGenFlags,
+		run:     runGen,
+	},
+	{
+		name:    "bench",
+		summary: "Time each phase on generated farms from tiny to 10k rooms / 100k ants.",
+		formats: []string{"text"},
+		setup:   addBenchFlags,
+		run:     runBench,
+	},
+}
+
+func addSolverFlag(fs *flag.FlagSet, opts *options) {
+	fs.StringVar(&opts.solver, "solver", "maxflow", "`name` of the solver: "+strings.Join(solver.Names(), ", "))
+	fs.StringVar(&opts.solver, "algorithm", "maxflow", "same as -solver")
+}
+
+func addVerbosityFlags(fs *flag.FlagSet, opts *options) {
+	fs.BoolVar(&opts.quiet, "q", false, "quiet: print nothing but the result")
+	fs.BoolVar(&opts.verbose, "v", false, "verbose: also print the farm summary and paths")
+}
+
 // Run executes the main application workflow.
 func Run() {
-	if len(os.Args) < 2 {
-		fmt.Println("Usage: go run . <input_file>")
-		os.Exit(1)
+	os.Exit(execute(os.Args[1:]))
+}
+
+// execute dispatches args to a subcommand and returns the exit code. When
+// the first argument is not a command name, "run" is assumed, so that
+// "lem-in <input_file>" keeps working.
+func execute(args []string) int {
+	if len(args) == 0 {
+		printUsage(os.Stderr)
+		return 1
+	}
+	switch args[0] {
+	case "help", "-h", "-help", "--help":
+		printUsage(os.Stdout)
+		return 0
 	}
-	inputFile := os.Args[1]
 
-	// Parse input
-	antCount, rooms, tunnels, err := parser.ParseInputFile(inputFile)
-	if err != nil {
-		fmt.Println(err)
-		os.Exit(1)
+	cmd := findCommand(args[0])
+	if cmd == nil {
+		cmd = findCommand("run")
+	} else {
+		args = args[1:]
 	}
 
-	// Basic validation
-	if antCount <= 0 {
-		fmt.Println("ERROR: invalid data format")
-		os.Exit(1)
+	opts := &options{}
+	fs := flag.NewFlagSet(cmd.name, flag.ContinueOnError)
+	fs.StringVar(&opts.output, "o", "", "write the output to `path` instead of stdout")
+	fs.StringVar(&opts.input, "input-format", "auto", "farm input `format`: auto, text or json")
+	fs.StringVar(&opts.format, "format", cmd.formats[0],
+		"output `format`: "+strings.Join(cmd.formats, ", "))
+	if cmd.setup != nil {
+		cmd.setup(fs, opts)
 	}
-	var startFound, endFound bool
-	for _, r := range rooms {
-		if r.IsStart {
-			startFound = true
+	fs.Usage = func() {
+		out := fs.Output()
+		fmt.Fprintf(out, "Usage: lem-in %s [flags] %s\n\n%s\n", cmd.name, cmd.args, cmd.summary)
+		if strings.Contains(cmd.args, "<input_file>") {
+			fmt.Fprintln(out, "An input file of \"-\" reads the farm from stdin.")
 		}
-		if r.IsEnd {
-			endFound = true
+		fmt.Fprintln(out, "\nFlags:")
+		fs.PrintDefaults()
+	}
+
+	if err := fs.Parse(args); err != nil {
+		if err == flag.ErrHelp {
+			return 0
 		}
+		return 2
 	}
-	if !startFound || !endFound {
-		fmt.Println("ERROR: invalid data format")
-		os.Exit(1)
+	if !containsString(cmd.formats, opts.format) {
+		fmt.Fprintf(os.Stderr, "unknown format %q for %s\n", opts.format, cmd.name)
+		fs.Usage()
+		return 2
 	}
+	if _, err := parser.ParseFormatName(opts.input); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		fs.Usage()
+		return 2
+	}
+	if fs.NArg() != strings.Count(cmd.args, "<") {
+		fs.Usage()
+		return 2
+	}
+	return cmd.run(opts, fs.Args())
+}
 
-	// Build graph and find paths
-	g, err := graph.BuildGraph(rooms, tunnels)
-	if err != nil {
-		fmt.Println(err)
-		os.Exit(1)
+func findCommand(name string) *command {
+	for _, cmd := range commands {
+		if cmd.name == name {
+			return cmd
+		}
 	}
-	paths, err := graph.GetOptimalPaths(g)
-	if err != nil || len(paths) == 0 {
-		fmt.Println("ERROR: invalid data format")
-		os.Exit(1)
+	return nil
+}
+
+// printUsage lists the commands.
+func printUsage(w io.Writer) {
+	fmt.Fprintln(w, "Usage: lem-in <command> [flags] <args>")
+	fmt.Fprintln(w, "       lem-in [flags] <input_file>   (same as run)")
+	fmt.Fprintln(w, "\nCommands:")
+	for _, cmd := range commands {
+		fmt.Fprintf(w, "  %-8s %s\n", cmd.name, cmd.summary)
 	}
+	fmt.Fprintln(w, "\nRun \"lem-in <command> -h\" for the flags of a command.")
+}
 
-	// Assign ants and simulate
-	assignment := scheduling.AssignAnts(antCount, paths)
-	extraInfo := visualizer.PrintExtraInfo(antCount, rooms, tunnels, paths, assignment)
-	simulation.SimulateMultiPath(antCount, paths, assignment, extraInfo)
+func containsString(list []string, value string) bool {
+	for _, item := range list {
+		if item == value {
+			return true
+		}
+	}
+	return false
 }
@@ -0,0 +1,182 @@
+package app
+
+import (
+	"bytes"
+	"flag"
+	"fmt"
+	"io"
+	"runtime"
+	"strings"
+	"time"
+
+	"lem-in/generator"
+	"lem-in/graph"
+	"lem-in/parser"
+	"lem-in/simulation"
+	"lem-in/solver"
+	"lem-in/structs"
+	"lem-in/visualizer"
+)
+
+// benchPhase is the cost of one phase of a benchmark run.
+type benchPhase struct {
+	name     string
+	duration time.Duration
+	allocs   uint64
+	bytes    uint64
+}
+
+func addBenchFlags(fs *flag.FlagSet, opts *options) {
+	names := make([]string, len(generator.Presets))
+	for i, preset := range generator.Presets {
+		names[i] = preset.Name
+	}
+	fs.StringVar(&opts.size, "size", "all", "farm `size`: "+strings.Join(names, ", ")+" or all")
+	fs.Int64Var(&opts.seed, "seed", 1, "random `seed` of the generated farms")
+	fs.Float64Var(&opts.degree, "degree", 4, "average number of tunnels per room")
+	fs.StringVar(&opts.topology, "topology", "grid",
+		"farm `shape`: "+strings.Join(generator.Topologies, ", "))
+	addSolverFlag(fs, opts)
+}
+
+// runBench implements "lem-in bench": it generates a farm of every chosen
+// size and prints the time and allocations of each phase of solving it,
+// along with the resulting number of turns.
+func runBench(opts *options, args []string) int {
+	var presets []generator.Preset
+	for _, preset := range generator.Presets {
+		if opts.size == "all" || opts.size == preset.Name {
+			presets = append(presets, preset)
+		}
+	}
+	if len(presets) == 0 {
+		fmt.Printf("unknown size %q\n", opts.size)
+		return 1
+	}
+
+	out, err := openOutput(opts.output)
+	if err != nil {
+		fmt.Println(err)
+		return 1
+	}
+	defer out.Close()
+
+	for i, preset := range presets {
+		if i > 0 {
+			fmt.Fprintln(out)
+		}
+		if err := benchPreset(out, opts, preset); err != nil {
+			fmt.Println(err)
+			return 1
+		}
+	}
+	return 0
+}
+
+// countTurns returns the number of turns of the plan, simulating it when the
+// solver left the scheduling to the simulation.
+func countTurns(g *structs.Graph, plan solver.Plan) (int, error) {
+	if plan.Schedule != nil {
+		return len(plan.Schedule.Turns), nil
+	}
+	return simulation.Run(g, plan.Assignment.Paths, plan.Assignment, nil)
+}
+
+// benchPreset solves one generated farm and prints its report.
+func benchPreset(w io.Writer, opts *options, preset generator.Preset) error {
+	farm, err := generator.Generate(generator.Options{
+		Seed:     opts.seed,
+		Rooms:    preset.Rooms,
+		Degree:   opts.degree,
+		Ants:     preset.Ants,
+		Topology: opts.topology,
+	})
+	if err != nil {
+		return err
+	}
+	var input bytes.Buffer
+	if err := visualizer.WriteFarm(&input, farm); err != nil {
+		return err
+	}
+
+	var phases []benchPhase
+	var g *structs.Graph
+	var plan solver.Plan
+	var turns int
+	steps := []struct {
+		name string
+		run  func() error
+	}{
+		{"parse", func() error {
+			farm, err = parser.Parse(&input)
+			return err
+		}},
+		{"graph", func() error {
+			g, err = graph.BuildGraph(farm.Rooms, farm.Tunnels)
+			return err
+		}},
+		{"solve", func() error {
+			plan, err = solvePlan(opts.solver, g, farm.AntCount)
+			return err
+		}},
+		{"simulate", func() error {
+			turns, err = countTurns(g, plan)
+			return err
+		}},
+	}
+	for _, step := range steps {
+		phase, err := measure(step.name, step.run)
+		if err != nil {
+			return fmt.Errorf("%s %s: %s: %v", opts.topology, preset.Name, step.name, err)
+		}
+		phases = append(phases, phase)
+	}
+
+	fmt.Fprintf(w, "%s %s: %d rooms, %d tunnels, %d ants, %d paths, %d turns (predicted %d)\n",
+		opts.topology, preset.Name, len(farm.Rooms), len(farm.Tunnels), farm.AntCount,
+		len(plan.Assignment.Paths), turns, plan.Assignment.PredictedTurns)
+	fmt.Fprintf(w, "  %-10s %12s %12s %12s\n", "phase", "time", "allocs", "bytes")
+	total := benchPhase{name: "total"}
+	for _, phase := range phases {
+		total.duration += phase.duration
+		total.allocs += phase.allocs
+		total.bytes += phase.bytes
+	}
+	for _, phase := range append(phases, total) {
+		fmt.Fprintf(w, "  %-10s %12s %12d %12s\n", phase.name,
+			phase.duration.Round(time.Microsecond), phase.allocs, formatBytes(phase.bytes))
+	}
+	return nil
+}
+
+// measure runs fn and records its duration and heap allocations.
+func measure(name string, fn func() error) (benchPhase, error) {
+	var before, after runtime.MemStats
+	runtime.ReadMemStats(&before)
+	started := time.Now()
+	err := fn()
+	duration := time.Since(started)
+	runtime.ReadMemStats(&after)
+	return benchPhase{
+		name:     name,
+		duration: duration,
+		allocs:   after.Mallocs - before.Mallocs,
+		bytes:    after.TotalAlloc - before.TotalAlloc,
+	}, err
+}
+
+// formatBytes prints a byte count with a binary unit.
+func formatBytes(n uint64) string {
+	const unit = 1024
+	if n < unit {
+		return fmt.Sprintf("%d B", n)
+	}
+	value, suffix := float64(n)/unit, "KiB"
+	for _, next := range []string{"MiB", "GiB"} {
+		if value < unit {
+			break
+		}
+		value, suffix = value/unit, next
+	}
+	return fmt.Sprintf("%.1f %s", value, suffix)
+}
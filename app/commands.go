@@ -0,0 +1,384 @@
+package app
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"time"
+
+	"lem-in/graph"
+	"lem-in/parser"
+	"lem-in/simulation"
+	"lem-in/solver"
+	"lem-in/structs"
+	"lem-in/verify"
+	"lem-in/visualizer"
+)
+
+// solution is a parsed farm together with the paths chosen for it.
+type solution struct {
+	farm       *structs.Farm
+	graph      *structs.Graph
+	candidates [][][]string
+	assignment structs.PathAssignment
+	bound      structs.LowerBound
+	schedule   *structs.SimResult // set by solvers that schedule the ants themselves
+	solver     string
+	events     []simulation.Event
+	replans    []string // what a run with events planned again, set by simulate
+	timings    []visualizer.PhaseTiming
+}
+
+// timePhase records how long the phase started at started took.
+func (sol *solution) timePhase(phase string, started time.Time) {
+	sol.timings = append(sol.timings, visualizer.PhaseTiming{Phase: phase, Duration: time.Since(started)})
+}
+
+// parseFarm reads a farm in the input format chosen by -input-format.
+func parseFarm(opts *options, r io.Reader) (*structs.Farm, error) {
+	format, err := parser.ParseFormatName(opts.input)
+	if err != nil {
+		return nil, err
+	}
+	return parser.ParseFormat(r, format)
+}
+
+// openInput opens the named farm file, or stdin for "-".
+func openInput(name string) (io.ReadCloser, error) {
+	if name == "-" {
+		return io.NopCloser(os.Stdin), nil
+	}
+	file, err := os.Open(name)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open file: %v", err)
+	}
+	return file, nil
+}
+
+// openOutput creates the file at path, or returns stdout when path is empty.
+func openOutput(path string) (io.WriteCloser, error) {
+	if path == "" || path == "-" {
+		return nopWriteCloser{os.Stdout}, nil
+	}
+	return os.Create(path)
+}
+
+type nopWriteCloser struct{ io.Writer }
+
+func (nopWriteCloser) Close() error { return nil }
+
+// printError reports err on stdout, with the canonical "ERROR: invalid data
+// format" line for invalid farms.
+func printError(err error) {
+	var parseErr *parser.ParseError
+	if errors.As(err, &parseErr) {
+		fmt.Println("ERROR: invalid data format")
+	}
+	fmt.Println(err)
+}
+
+// solve parses the farm named inputFile and plans the ants' moves with the
+// chosen solver.
+func solve(opts *options, inputFile string) (*solution, error) {
+	input, err := openInput(inputFile)
+	if err != nil {
+		return nil, err
+	}
+	sol := &solution{}
+	started := time.Now()
+	farm, err := parseFarm(opts, input)
+	input.Close()
+	if err != nil {
+		return nil, err
+	}
+	sol.farm = farm
+	sol.timePhase("parse", started)
+
+	started = time.Now()
+	g, err := graph.BuildGraph(farm.Rooms, farm.Tunnels)
+	if err != nil {
+		return nil, err
+	}
+	sol.graph = g
+	sol.timePhase("graph", started)
+
+	if opts.events != "" {
+		if sol.events, err = readEvents(opts.events, g); err != nil {
+			return nil, err
+		}
+	}
+
+	sol.solver = opts.solver
+	if len(sol.events) == 0 {
+		// with events the ants are planned as the run goes, by simulate
+		started = time.Now()
+		plan, err := solvePlan(opts.solver, g, farm.AntCount)
+		if err != nil {
+			return nil, err
+		}
+		sol.candidates, sol.assignment, sol.schedule = plan.Candidates, plan.Assignment, plan.Schedule
+		sol.timePhase("solve", started)
+	}
+
+	started = time.Now()
+	sol.bound, err = graph.GetLowerBound(g, farm.AntCount)
+	if err != nil {
+		return nil, err
+	}
+	sol.timePhase("bound", started)
+	return sol, nil
+}
+
+// readEvents reads the event script at path and checks it against the farm.
+func readEvents(path string, g *structs.Graph) ([]simulation.Event, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open events: %v", err)
+	}
+	defer file.Close()
+	events, err := simulation.ParseEvents(file)
+	if err != nil {
+		return nil, err
+	}
+	return events, simulation.CheckEvents(g, events)
+}
+
+// solvePlan runs the named solver. A farm without any path is reported as
+// invalid data.
+func solvePlan(name string, g *structs.Graph, antCount int) (solver.Plan, error) {
+	s, err := solver.Get(name)
+	if err != nil {
+		return solver.Plan{}, err
+	}
+	plan, err := s.Solve(g, antCount)
+	if errors.Is(err, solver.ErrNoPath) {
+		return solver.Plan{}, errors.New("ERROR: invalid data format")
+	}
+	return plan, err
+}
+
+// simulate runs the simulation of the solution and records its timing.
+func (sol *solution) simulate() (structs.SimResult, error) {
+	if len(sol.events) > 0 {
+		started := time.Now()
+		result, err := simulation.SimulateEvents(sol.graph, sol.farm.AntCount, sol.events,
+			func(g *structs.Graph, antCount int) (structs.PathAssignment, error) {
+				plan, err := solvePlan(sol.solver, g, antCount)
+				return plan.Assignment, err
+			})
+		sol.replans = result.Replans
+		sol.timePhase("simulate", started)
+		return result, err
+	}
+	if sol.schedule != nil {
+		return *sol.schedule, nil
+	}
+	started := time.Now()
+	result, err := simulation.Simulate(sol.graph, sol.assignment.Paths, sol.assignment)
+	sol.timePhase("simulate", started)
+	return result, err
+}
+
+// header builds the input, summary and path info shown with -v and in the
+// grid report. A run with events shows its re-plans instead of the paths.
+func (sol *solution) header() string {
+	if len(sol.events) > 0 {
+		return visualizer.PrintReplanInfo(sol.farm.AntCount, sol.farm.Rooms, sol.farm.Tunnels, sol.replans)
+	}
+	var allPaths [][]string
+	seen := make(map[string]bool)
+	for _, candidate := range sol.candidates {
+		for _, path := range candidate {
+			key := strings.Join(path, " ")
+			if !seen[key] {
+				seen[key] = true
+				allPaths = append(allPaths, path)
+			}
+		}
+	}
+	return visualizer.PrintExtraInfo(sol.farm.AntCount, sol.farm.Rooms, sol.farm.Tunnels,
+		allPaths, sol.assignment)
+}
+
+// runSolve implements "lem-in run".
+func runSolve(opts *options, args []string) int {
+	if opts.strict {
+		opts.format = "subject"
+	}
+	sol, err := solve(opts, args[0])
+	if err != nil {
+		printError(err)
+		return 1
+	}
+	result, err := sol.simulate()
+	if err != nil {
+		fmt.Println("ERROR:", err)
+		return 1
+	}
+	if opts.animate {
+		err := visualizer.Animate(sol.farm, result)
+		if errors.Is(err, visualizer.ErrInterrupted) {
+			// the status a shell reports for a process stopped by Ctrl-C
+			return 130
+		}
+		if err != nil {
+			fmt.Println(err)
+			return 1
+		}
+		return 0
+	}
+
+	if !opts.quiet {
+		for _, entry := range result.Replans {
+			fmt.Fprintln(os.Stderr, "replan:", entry)
+		}
+	}
+
+	out, err := openOutput(opts.output)
+	if err != nil {
+		fmt.Println(err)
+		return 1
+	}
+	defer out.Close()
+
+	switch opts.format {
+	case "json":
+		err = visualizer.WriteJSON(out, sol.farm, sol.candidates, sol.assignment, result,
+			sol.bound, sol.timings)
+		if err != nil {
+			fmt.Println(err)
+			return 1
+		}
+	case "subject":
+		visualizer.WriteSubjectOutput(out, sol.farm.Lines, result.Turns)
+	case "text":
+		header := sol.header()
+		if opts.verbose && !opts.quiet {
+			fmt.Fprint(out, header)
+			fmt.Fprintln(out, visualizer.BuildOptimality(sol.farm.AntCount, sol.bound, len(result.Turns)))
+		}
+		moveOutputs := make([]string, len(result.Turns))
+		for i, moves := range result.Turns {
+			moveOutputs[i] = visualizer.FormatMoves(moves)
+		}
+		visualizer.WriteTerminalOutput(out, moveOutputs)
+
+		if opts.gridFile != "" {
+			err := visualizer.WriteSimulationOutput(opts.gridFile, header, result.Grids, len(result.Turns))
+			if err != nil {
+				fmt.Fprintln(os.Stderr, "Error writing simulation output:", err)
+				return 1
+			}
+			if !opts.quiet {
+				fmt.Fprintln(os.Stderr, "2D grid visualization written to", opts.gridFile)
+			}
+		}
+	}
+	return 0
+}
+
+// runStats implements "lem-in stats".
+func runStats(opts *options, args []string) int {
+	sol, err := solve(opts, args[0])
+	if err != nil {
+		printError(err)
+		return 1
+	}
+
+	out, err := openOutput(opts.output)
+	if err != nil {
+		fmt.Println(err)
+		return 1
+	}
+	defer out.Close()
+
+	fmt.Fprint(out, visualizer.BuildStats(sol.farm.AntCount, sol.farm.Rooms, sol.farm.Tunnels,
+		len(sol.candidates), sol.assignment))
+	fmt.Fprintln(out)
+	fmt.Fprint(out, visualizer.BuildOptimality(sol.farm.AntCount, sol.bound, sol.assignment.PredictedTurns))
+	return 0
+}
+
+// runRender implements "lem-in render".
+func runRender(opts *options, args []string) int {
+	sol, err := solve(opts, args[0])
+	if err != nil {
+		printError(err)
+		return 1
+	}
+	result, err := sol.simulate()
+	if err != nil {
+		fmt.Println("ERROR:", err)
+		return 1
+	}
+
+	out, err := openOutput(opts.output)
+	if err != nil {
+		fmt.Println(err)
+		return 1
+	}
+	defer out.Close()
+
+	switch opts.format {
+	case "svg":
+		err = visualizer.WriteSVG(out, sol.farm, sol.assignment)
+	case "html":
+		err = visualizer.WriteHTML(out, sol.farm, sol.assignment, result.Turns)
+	case "dot":
+		err = graph.WriteDOT(out, sol.graph, sol.assignment.Paths)
+	default:
+		_, err = fmt.Fprint(out, visualizer.BuildSimulationReport(sol.header(), result.Grids, len(result.Turns)))
+	}
+	if err != nil {
+		fmt.Println(err)
+		return 1
+	}
+	return 0
+}
+
+// runVerify implements "lem-in verify".
+func runVerify(opts *options, args []string) int {
+	input, err := openInput(args[0])
+	if err != nil {
+		fmt.Println(err)
+		return 1
+	}
+	farm, err := parseFarm(opts, input)
+	input.Close()
+	if err != nil {
+		printError(err)
+		return 1
+	}
+	g, err := graph.BuildGraph(farm.Rooms, farm.Tunnels)
+	if err != nil {
+		printError(err)
+		return 1
+	}
+
+	movesInput, err := openInput(args[1])
+	if err != nil {
+		fmt.Println(err)
+		return 1
+	}
+	turns, err := verify.ParseMoves(movesInput)
+	movesInput.Close()
+	if err == nil {
+		_, err = verify.Verify(g, farm.AntCount, turns)
+	}
+
+	out, outErr := openOutput(opts.output)
+	if outErr != nil {
+		fmt.Println(outErr)
+		return 1
+	}
+	defer out.Close()
+
+	if err != nil {
+		fmt.Fprintln(out, "INVALID:", err)
+		return 1
+	}
+	fmt.Fprintf(out, "OK: %d ants reached the end room in %d turns\n", farm.AntCount, len(turns))
+	return 0
+}
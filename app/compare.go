@@ -0,0 +1,62 @@
+package app
+
+import (
+	"fmt"
+	"time"
+
+	"lem-in/graph"
+	"lem-in/solver"
+)
+
+// runCompare implements "lem-in compare": it runs every registered solver
+// on the farm and tabulates their turns and runtime.
+func runCompare(opts *options, args []string) int {
+	input, err := openInput(args[0])
+	if err != nil {
+		fmt.Println(err)
+		return 1
+	}
+	farm, err := parseFarm(opts, input)
+	input.Close()
+	if err != nil {
+		printError(err)
+		return 1
+	}
+	g, err := graph.BuildGraph(farm.Rooms, farm.Tunnels)
+	if err != nil {
+		printError(err)
+		return 1
+	}
+	bound, err := graph.GetLowerBound(g, farm.AntCount)
+	if err != nil {
+		fmt.Println("ERROR: invalid data format")
+		return 1
+	}
+
+	out, err := openOutput(opts.output)
+	if err != nil {
+		fmt.Println(err)
+		return 1
+	}
+	defer out.Close()
+
+	fmt.Fprintf(out, "%d ants, lower bound %d turns (shortest path %d, min cut %d)\n\n",
+		farm.AntCount, bound.Turns, bound.ShortestPath, bound.CutSize)
+	fmt.Fprintf(out, "%-14s %8s %6s %6s %12s\n", "solver", "turns", "gap", "paths", "time")
+	for _, name := range solver.Names() {
+		started := time.Now()
+		plan, err := solvePlan(name, g, farm.AntCount)
+		turns := 0
+		if err == nil {
+			turns, err = countTurns(g, plan)
+		}
+		elapsed := time.Since(started)
+		if err != nil {
+			fmt.Fprintf(out, "%-14s error: %v\n", name, err)
+			continue
+		}
+		fmt.Fprintf(out, "%-14s %8d %6d %6d %12s\n", name, turns, turns-bound.Turns,
+			len(plan.Assignment.Paths), elapsed.Round(time.Microsecond))
+	}
+	return 0
+}
@@ -0,0 +1,47 @@
+package app
+
+import (
+	"flag"
+	"fmt"
+	"strings"
+
+	"lem-in/generator"
+	"lem-in/visualizer"
+)
+
+func addGenFlags(fs *flag.FlagSet, opts *options) {
+	fs.Int64Var(&opts.seed, "seed", 1, "random `seed`; the same flags always give the same farm")
+	fs.IntVar(&opts.rooms, "rooms", 20, "number of `rooms`, start and end included")
+	fs.Float64Var(&opts.degree, "degree", 3, "average number of tunnels per room (grid, geometric, layered)")
+	fs.IntVar(&opts.ants, "ants", 10, "number of `ants`")
+	fs.StringVar(&opts.topology, "topology", "grid",
+		"farm `shape`: "+strings.Join(generator.Topologies, ", "))
+}
+
+// runGen implements "lem-in gen": it writes a generated farm in the input
+// text format.
+func runGen(opts *options, args []string) int {
+	farm, err := generator.Generate(generator.Options{
+		Seed:     opts.seed,
+		Rooms:    opts.rooms,
+		Degree:   opts.degree,
+		Ants:     opts.ants,
+		Topology: opts.topology,
+	})
+	if err != nil {
+		fmt.Println(err)
+		return 1
+	}
+
+	out, err := openOutput(opts.output)
+	if err != nil {
+		fmt.Println(err)
+		return 1
+	}
+	defer out.Close()
+	if err := visualizer.WriteFarm(out, farm); err != nil {
+		fmt.Println(err)
+		return 1
+	}
+	return 0
+}
@@ -0,0 +1,249 @@
+package app
+
+import (
+	"bytes"
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"lem-in/parser"
+	"lem-in/solver"
+	"lem-in/verify"
+	"lem-in/visualizer"
+)
+
+var update = flag.Bool("update", false, "rewrite the golden files in testdata/golden")
+
+// goldenCases lists every farm in examples/. Valid farms are checked against
+// their golden moves; invalid ones must fail with the given error kind.
+var goldenCases = []struct {
+	file    string
+	wantErr error
+}{
+	{file: "example00.txt"},
+	{file: "example01.txt"},
+	{file: "example02.txt"},
+	{file: "example03.txt"},
+	{file: "example04.txt"},
+	{file: "example05.txt"},
+	{file: "example06.txt"},
+	{file: "example07.txt"},
+	{file: "badexample00.txt", wantErr: parser.BadAntCount},
+	{file: "badexample01.txt", wantErr: parser.SelfLoop},
+	{file: "capacity00.txt"},
+	{file: "badcapacity00.txt", wantErr: parser.BadCapacity},
+	{file: "weighted00.txt"},
+	{file: "weighted01.txt"},
+	{file: "badweight00.txt", wantErr: parser.BadWeight},
+}
+
+func TestExamples(t *testing.T) {
+	files, err := filepath.Glob(filepath.Join("..", "examples", "*.txt"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	listed := make(map[string]bool)
+	for _, tc := range goldenCases {
+		listed[tc.file] = true
+	}
+	for _, file := range files {
+		if !listed[filepath.Base(file)] {
+			t.Errorf("%s has no entry in goldenCases", file)
+		}
+	}
+
+	for _, tc := range goldenCases {
+		t.Run(strings.TrimSuffix(tc.file, ".txt"), func(t *testing.T) {
+			opts := &options{input: "auto", solver: "maxflow"}
+			sol, err := solve(opts, filepath.Join("..", "examples", tc.file))
+			if tc.wantErr != nil {
+				var parseErr *parser.ParseError
+				if !errors.As(err, &parseErr) || !errors.Is(err, tc.wantErr) {
+					t.Fatalf("got error %v, want a parse error %q", err, tc.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			result, err := sol.simulate()
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			turns, err := verify.Verify(sol.graph, sol.farm.AntCount, result.Turns)
+			if err != nil {
+				t.Fatalf("schedule rejected by the verifier: %v", err)
+			}
+			if turns != sol.assignment.PredictedTurns {
+				t.Errorf("took %d turns, predicted %d", turns, sol.assignment.PredictedTurns)
+			}
+			if turns < sol.bound.Turns {
+				t.Errorf("took %d turns, below the lower bound of %d", turns, sol.bound.Turns)
+			}
+
+			// the subject output, and the moves alone, must read back as
+			// the same schedule
+			var subject, movesOnly bytes.Buffer
+			visualizer.WriteSubjectOutput(&subject, sol.farm.Lines, result.Turns)
+			for _, moves := range result.Turns {
+				movesOnly.WriteString(visualizer.FormatMoves(moves) + "\n")
+			}
+			for name, output := range map[string]*bytes.Buffer{"subject": &subject, "moves-only": &movesOnly} {
+				parsed, err := verify.ParseMoves(output)
+				if err != nil {
+					t.Fatal(err)
+				}
+				if reread, err := verify.Verify(sol.graph, sol.farm.AntCount, parsed); err != nil || reread != turns {
+					t.Errorf("%s output reads back as %d turns (%v), want %d", name, reread, err, turns)
+				}
+			}
+
+			var got strings.Builder
+			fmt.Fprintf(&got, "turns: %d\n", len(result.Turns))
+			for _, moves := range result.Turns {
+				got.WriteString(visualizer.FormatMoves(moves) + "\n")
+			}
+			checkGolden(t, strings.TrimSuffix(tc.file, ".txt")+".golden", got.String())
+		})
+	}
+}
+
+// TestSolvers runs every registered solver on the valid examples. Their
+// schedules must pass the verifier and respect the lower bound, and the
+// time-expanded solver must never need more turns than the others.
+func TestSolvers(t *testing.T) {
+	for _, tc := range goldenCases {
+		if tc.wantErr != nil {
+			continue
+		}
+		path := filepath.Join("..", "examples", tc.file)
+		turns := make(map[string]int)
+		for _, name := range solver.Names() {
+			t.Run(strings.TrimSuffix(tc.file, ".txt")+"/"+name, func(t *testing.T) {
+				sol, err := solve(&options{input: "auto", solver: name}, path)
+				if err != nil {
+					t.Fatal(err)
+				}
+				result, err := sol.simulate()
+				if err != nil {
+					t.Fatal(err)
+				}
+				turns[name], err = verify.Verify(sol.graph, sol.farm.AntCount, result.Turns)
+				if err != nil {
+					t.Fatalf("schedule rejected by the verifier: %v", err)
+				}
+				if turns[name] < sol.bound.Turns {
+					t.Errorf("took %d turns, below the lower bound of %d", turns[name], sol.bound.Turns)
+				}
+			})
+		}
+		for name, n := range turns {
+			if n < turns["timeexpanded"] {
+				t.Errorf("%s: %s took %d turns, fewer than timeexpanded (%d)", tc.file, name, n, turns["timeexpanded"])
+			}
+		}
+	}
+}
+
+// TestEvents replays the event scripts in testdata/events over their farms:
+// every ant must still arrive with legal moves, and no move may cross a
+// closed tunnel or enter a closed room.
+func TestEvents(t *testing.T) {
+	tests := []struct {
+		farm   string
+		events string
+	}{
+		{filepath.Join("..", "examples", "example01.txt"), "example01.events"},
+		// ants inside the slow tunnel s-a when a-e closes
+		{filepath.Join("testdata", "events", "weighted.txt"), "weighted.events"},
+	}
+	for _, tc := range tests {
+		t.Run(tc.events, func(t *testing.T) {
+			opts := &options{input: "auto", solver: "maxflow", events: filepath.Join("testdata", "events", tc.events)}
+			sol, err := solve(opts, tc.farm)
+			if err != nil {
+				t.Fatal(err)
+			}
+			result, err := sol.simulate()
+			if err != nil {
+				t.Fatal(err)
+			}
+			if _, err := verify.Verify(sol.graph, sol.farm.AntCount, result.Turns); err != nil {
+				t.Fatalf("schedule rejected by the verifier: %v", err)
+			}
+			if len(result.Replans) == 0 {
+				t.Error("no re-plans logged")
+			}
+
+			closed := make(map[string]bool)
+			positions := make(map[int]string)
+			next := 0
+			for i, moves := range result.Turns {
+				for ; next < len(sol.events) && sol.events[next].Turn == i+1; next++ {
+					event := sol.events[next]
+					key := event.RoomA
+					if event.IsTunnel() {
+						key = tunnelName(event.RoomA, event.RoomB)
+					}
+					closed[key] = !event.Open
+				}
+				for _, move := range moves {
+					from, ok := positions[move.Ant]
+					if !ok {
+						from = sol.farm.Start
+					}
+					if closed[move.To] || closed[tunnelName(from, move.To)] {
+						t.Errorf("turn %d: %s goes through a closed room or tunnel", i+1, move)
+					}
+					positions[move.Ant] = move.To
+				}
+			}
+		})
+	}
+}
+
+func tunnelName(a, b string) string {
+	if a > b {
+		a, b = b, a
+	}
+	return a + "-" + b
+}
+
+// checkGolden compares got with testdata/golden/name, or rewrites the file
+// when the test runs with -update.
+func checkGolden(t *testing.T, name, got string) {
+	t.Helper()
+	path := filepath.Join("testdata", "golden", name)
+	if *update {
+		if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(got), 0o644); err != nil {
+			t.Fatal(err)
+		}
+		return
+	}
+	want, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("%v (run go test ./app -update to create it)", err)
+	}
+	if got == string(want) {
+		return
+	}
+	gotLines := strings.Split(got, "\n")
+	wantLines := strings.Split(string(want), "\n")
+	if gotLines[0] != wantLines[0] {
+		t.Errorf("%s: got %q, golden has %q", name, gotLines[0], wantLines[0])
+	}
+	for i := 1; i < len(gotLines) && i < len(wantLines); i++ {
+		if gotLines[i] != wantLines[i] {
+			t.Fatalf("%s: turn %d differs\n got: %s\nwant: %s", name, i, gotLines[i], wantLines[i])
+		}
+	}
+	t.Fatalf("%s: got %d lines, golden has %d", name, len(gotLines), len(wantLines))
+}
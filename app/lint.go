@@ -0,0 +1,101 @@
+package app
+
+import (
+	"fmt"
+	"io"
+	"sort"
+
+	"lem-in/graph"
+	"lem-in/parser"
+	"lem-in/structs"
+)
+
+// runLint implements "lem-in lint": it checks the farm file and prints every
+// problem found. It returns 0 for a clean farm, 1 otherwise.
+func runLint(opts *options, args []string) int {
+	inputFile := args[0]
+	input, err := openInput(inputFile)
+	if err != nil {
+		fmt.Println(err)
+		return 1
+	}
+	format, err := parser.ParseFormatName(opts.input)
+	if err != nil {
+		fmt.Println(err)
+		return 1
+	}
+	problems, err := lintFarm(input, format)
+	input.Close()
+	if err != nil {
+		fmt.Println(err)
+		return 1
+	}
+
+	out, err := openOutput(opts.output)
+	if err != nil {
+		fmt.Println(err)
+		return 1
+	}
+	defer out.Close()
+
+	if inputFile == "-" {
+		inputFile = "<stdin>"
+	}
+	for _, problem := range problems {
+		if problem.Line == 0 {
+			fmt.Fprintf(out, "%s: %s\n", inputFile, problem.Message())
+			continue
+		}
+		fmt.Fprintf(out, "%s:%d:%d: %s\n", inputFile, problem.Line, problem.Column, problem.Message())
+	}
+	if len(problems) > 0 {
+		fmt.Fprintf(out, "%d problem(s) found\n", len(problems))
+		return 1
+	}
+	fmt.Fprintln(out, "no problems found")
+	return 0
+}
+
+// lintFarm parses r without stopping at the first error, then checks the
+// rooms that were read for reachability from the start room.
+func lintFarm(r io.Reader, format parser.Format) ([]*parser.ParseError, error) {
+	farm, problems, err := parser.ParseAll(r, format)
+	if err != nil {
+		return nil, err
+	}
+	if farm.Start == "" || farm.End == "" {
+		return problems, nil
+	}
+
+	// skip tunnels whose rooms were rejected, they are already reported
+	roomLines := make(map[string]int, len(farm.Rooms))
+	for _, room := range farm.Rooms {
+		roomLines[room.Name] = room.Line
+	}
+	var tunnels []structs.Tunnel
+	for _, t := range farm.Tunnels {
+		if _, ok := roomLines[t.RoomA]; !ok {
+			continue
+		}
+		if _, ok := roomLines[t.RoomB]; !ok {
+			continue
+		}
+		tunnels = append(tunnels, t)
+	}
+
+	g, err := graph.BuildGraph(farm.Rooms, tunnels)
+	if err != nil {
+		return nil, err
+	}
+	for _, name := range graph.UnreachableRooms(g) {
+		kind := parser.UnreachableRoom
+		if name == farm.End {
+			kind = parser.NoPath
+		}
+		problems = append(problems, &parser.ParseError{Kind: kind, Line: roomLines[name], Column: 1, Text: name})
+	}
+	sort.SliceStable(problems, func(i, j int) bool {
+		return problems[i].Line < problems[j].Line
+	})
+	return problems, nil
+}
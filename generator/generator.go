@@ -0,0 +1,454 @@
+package generator
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"math/rand"
+	"sort"
+
+	"lem-in/structs"
+)
+
+// Topologies lists the farm shapes Generate knows.
+var Topologies = []string{"grid", "geometric", "layered", "corridor", "trap"}
+
+// Preset is a named farm size, from tiny up to the largest farms lem-in is
+// expected to handle. Benchmarks run over every preset.
+type Preset struct {
+	Name  string
+	Rooms int
+	Ants  int
+}
+
+// Presets lists the farm sizes in increasing order.
+var Presets = []Preset{
+	{Name: "tiny", Rooms: 10, Ants: 10},
+	{Name: "small", Rooms: 100, Ants: 1000},
+	{Name: "medium", Rooms: 1000, Ants: 10000},
+	{Name: "large", Rooms: 10000, Ants: 100000},
+}
+
+// Options describes the farm to generate.
+type Options struct {
+	Seed     int64
+	Rooms    int
+	Degree   float64 // average tunnels per room (grid, geometric, layered)
+	Ants     int
+	Topology string
+}
+
+// builder accumulates rooms and tunnels while keeping coordinates and
+// tunnels unique.
+type builder struct {
+	rng     *rand.Rand
+	rooms   []structs.Room
+	coords  map[[2]int]bool
+	tunnels map[[2]int]bool
+	links   []structs.Tunnel
+	adj     [][]int
+}
+
+// Generate builds a valid farm: unique room names and coordinates, no
+// self-loops or repeated tunnels, and the end room reachable from the start.
+// The same options always produce the same farm.
+func Generate(opts Options) (*structs.Farm, error) {
+	if opts.Rooms < 2 {
+		return nil, errors.New("a farm needs at least 2 rooms")
+	}
+	if opts.Ants < 1 {
+		return nil, errors.New("a farm needs at least 1 ant")
+	}
+	if opts.Degree <= 0 {
+		opts.Degree = 3
+	}
+
+	b := &builder{
+		rng:     rand.New(rand.NewSource(opts.Seed)),
+		coords:  make(map[[2]int]bool),
+		tunnels: make(map[[2]int]bool),
+	}
+	var start, end int
+	switch opts.Topology {
+	case "grid":
+		start, end = b.grid(opts.Rooms, opts.Degree)
+	case "geometric":
+		start, end = b.geometric(opts.Rooms, opts.Degree)
+	case "layered":
+		start, end = b.layered(opts.Rooms, opts.Degree)
+	case "corridor":
+		start, end = b.corridor(opts.Rooms)
+	case "trap":
+		start, end = b.trap(opts.Rooms)
+	default:
+		return nil, fmt.Errorf("unknown topology %q", opts.Topology)
+	}
+	if !b.connected(start, end) {
+		return nil, errors.New("generated farm has no path from start to end")
+	}
+
+	b.rooms[start].IsStart = true
+	b.rooms[end].IsEnd = true
+	farm := &structs.Farm{
+		AntCount: opts.Ants,
+		Rooms:    b.rooms,
+		Tunnels:  b.links,
+		Start:    b.rooms[start].Name,
+		End:      b.rooms[end].Name,
+	}
+	return farm, nil
+}
+
+// addRoom adds a room at (x, y), or at the first free spot below it.
+func (b *builder) addRoom(x, y int) int {
+	for b.coords[[2]int{x, y}] {
+		y++
+	}
+	b.coords[[2]int{x, y}] = true
+	b.rooms = append(b.rooms, structs.Room{Name: fmt.Sprintf("r%d", len(b.rooms)), X: x, Y: y})
+	b.adj = append(b.adj, nil)
+	return len(b.rooms) - 1
+}
+
+// link adds a tunnel between rooms i and j unless it is a self-loop or
+// already exists. It reports whether a tunnel was added.
+func (b *builder) link(i, j int) bool {
+	if i == j {
+		return false
+	}
+	key := [2]int{i, j}
+	if i > j {
+		key = [2]int{j, i}
+	}
+	if b.tunnels[key] {
+		return false
+	}
+	b.tunnels[key] = true
+	b.links = append(b.links, structs.Tunnel{RoomA: b.rooms[i].Name, RoomB: b.rooms[j].Name})
+	b.adj[i] = append(b.adj[i], j)
+	b.adj[j] = append(b.adj[j], i)
+	return true
+}
+
+// connected reports whether room to can be reached from room from.
+func (b *builder) connected(from, to int) bool {
+	seen := make([]bool, len(b.rooms))
+	seen[from] = true
+	queue := []int{from}
+	for len(queue) > 0 {
+		room := queue[0]
+		queue = queue[1:]
+		if room == to {
+			return true
+		}
+		for _, next := range b.adj[room] {
+			if !seen[next] {
+				seen[next] = true
+				queue = append(queue, next)
+			}
+		}
+	}
+	return false
+}
+
+// targetLinks is the tunnel count giving the requested average degree.
+func targetLinks(rooms int, degree float64) int {
+	return int(math.Round(float64(rooms) * degree / 2))
+}
+
+// grid lays rooms on a near-square grid, keeps a random spanning tree of the
+// grid tunnels and adds the other grid tunnels until the degree is reached.
+// Start and end are opposite corners.
+func (b *builder) grid(rooms int, degree float64) (int, int) {
+	width := int(math.Ceil(math.Sqrt(float64(rooms))))
+	for i := 0; i < rooms; i++ {
+		b.addRoom(i%width, i/width)
+	}
+
+	var candidates [][2]int
+	for i := 0; i < rooms; i++ {
+		if i%width+1 < width && i+1 < rooms {
+			candidates = append(candidates, [2]int{i, i + 1})
+		}
+		if i+width < rooms {
+			candidates = append(candidates, [2]int{i, i + width})
+		}
+	}
+	b.spanAndFill(candidates, targetLinks(rooms, degree))
+	return 0, rooms - 1
+}
+
+// geometric scatters rooms at random and links each pair closer than the
+// radius that gives the requested degree; stray components are joined to
+// their nearest neighbor. Start and end are the leftmost and rightmost rooms.
+func (b *builder) geometric(rooms int, degree float64) (int, int) {
+	side := int(math.Ceil(math.Sqrt(float64(rooms)))) * 10
+	for i := 0; i < rooms; i++ {
+		b.addRoom(b.rng.Intn(side), b.rng.Intn(side))
+	}
+	radius := math.Sqrt(degree/(math.Pi*float64(rooms))) * float64(side)
+
+	// bucket rooms by radius-sized cells so only nearby pairs are compared
+	cell := int(math.Ceil(radius))
+	if cell < 1 {
+		cell = 1
+	}
+	buckets := make(map[[2]int][]int)
+	for i, room := range b.rooms {
+		key := [2]int{room.X / cell, room.Y / cell}
+		buckets[key] = append(buckets[key], i)
+	}
+	distance := func(i, j int) float64 {
+		return math.Hypot(float64(b.rooms[i].X-b.rooms[j].X), float64(b.rooms[i].Y-b.rooms[j].Y))
+	}
+	for i, room := range b.rooms {
+		cx, cy := room.X/cell, room.Y/cell
+		for dx := -1; dx <= 1; dx++ {
+			for dy := -1; dy <= 1; dy++ {
+				for _, j := range buckets[[2]int{cx + dx, cy + dy}] {
+					if j > i && distance(i, j) <= radius {
+						b.link(i, j)
+					}
+				}
+			}
+		}
+	}
+
+	// join every other component to the nearest room of the first one
+	component := b.components()
+	for {
+		var stray []int
+		for i := range b.rooms {
+			if component[i] != component[0] {
+				stray = append(stray, i)
+			}
+		}
+		if len(stray) == 0 {
+			break
+		}
+		best, bestFrom, bestTo := math.Inf(1), -1, -1
+		for _, i := range stray {
+			if component[i] != component[stray[0]] {
+				continue
+			}
+			for j := range b.rooms {
+				if component[j] == component[0] && distance(i, j) < best {
+					best, bestFrom, bestTo = distance(i, j), i, j
+				}
+			}
+		}
+		b.link(bestFrom, bestTo)
+		component = b.components()
+	}
+
+	start, end := 0, 0
+	for i, room := range b.rooms {
+		if room.X < b.rooms[start].X {
+			start = i
+		}
+		if room.X > b.rooms[end].X {
+			end = i
+		}
+	}
+	if start == end {
+		end = (start + 1) % rooms
+	}
+	return start, end
+}
+
+// layered stacks rooms in layers between start and end with a narrow
+// bottleneck layer in the middle. Each room links to rooms in the next
+// layer until the degree is reached.
+func (b *builder) layered(rooms int, degree float64) (int, int) {
+	start := b.addRoom(0, 0)
+	inner := rooms - 2
+	if inner <= 0 {
+		end := b.addRoom(1, 0)
+		b.link(start, end)
+		return start, end
+	}
+
+	width := int(math.Max(2, math.Round(math.Sqrt(float64(inner)))))
+	var layers [][]int
+	for placed := 0; placed < inner; {
+		size := width
+		if len(layers) > 0 && len(layers)%4 == 2 {
+			size = int(math.Max(1, float64(width/4))) // bottleneck
+		}
+		if size > inner-placed {
+			size = inner - placed
+		}
+		var layer []int
+		for k := 0; k < size; k++ {
+			layer = append(layer, b.addRoom(len(layers)+1, k))
+		}
+		layers = append(layers, layer)
+		placed += size
+	}
+	end := b.addRoom(len(layers)+1, 0)
+
+	for _, room := range layers[0] {
+		b.link(start, room)
+	}
+	for _, room := range layers[len(layers)-1] {
+		b.link(room, end)
+	}
+	// every room gets at least one tunnel forward and one backward
+	var candidates [][2]int
+	for l := 0; l+1 < len(layers); l++ {
+		for _, from := range layers[l] {
+			for _, to := range layers[l+1] {
+				candidates = append(candidates, [2]int{from, to})
+			}
+		}
+		for k, from := range layers[l] {
+			b.link(from, layers[l+1][k%len(layers[l+1])])
+		}
+		for k, to := range layers[l+1] {
+			b.link(layers[l][k%len(layers[l])], to)
+		}
+	}
+	b.fill(candidates, targetLinks(rooms, degree))
+	return start, end
+}
+
+// corridor builds one long corridor from start to end with dead-end side
+// rooms branching off it.
+func (b *builder) corridor(rooms int) (int, int) {
+	length := rooms/2 + 1
+	if length < 2 {
+		length = 2
+	}
+	for i := 0; i < length; i++ {
+		b.addRoom(i, 0)
+		if i > 0 {
+			b.link(i-1, i)
+		}
+	}
+	// side rooms hang off the rooms between start and end, so a corridor
+	// of start and end alone gets none
+	for side := length; side < rooms && length > 2; side++ {
+		anchor := 1 + b.rng.Intn(length-2)
+		b.link(anchor, b.addRoom(anchor, 1))
+	}
+	return 0, length - 1
+}
+
+// trap builds units of two parallel corridors joined by a shortcut. The
+// shortcut is the shortest path but uses a room of each corridor, so a
+// greedy shortest-path choice finds one route where the unit holds two.
+// Leftover rooms become dead ends.
+func (b *builder) trap(rooms int) (int, int) {
+	start := b.addRoom(0, 0)
+	inner := rooms - 2
+	units := inner / 8
+	if units < 1 {
+		units = 1
+	}
+	length := inner / (2 * units)
+	if length < 2 {
+		length = 2
+	}
+	end := b.addRoom(length+1, 0)
+
+	var chainRooms []int
+	for u := 0; u < units && len(b.rooms)+2*length <= rooms; u++ {
+		var chains [2][]int
+		for c := 0; c < 2; c++ {
+			prev := start
+			for i := 0; i < length; i++ {
+				room := b.addRoom(i+1, 3*u+c+1)
+				b.link(prev, room)
+				chains[c] = append(chains[c], room)
+				prev = room
+			}
+			b.link(prev, end)
+		}
+		// shortcut from the head of one corridor to the tail of the other
+		b.link(chains[0][0], chains[1][length-1])
+		chainRooms = append(chainRooms, chains[0]...)
+		chainRooms = append(chainRooms, chains[1]...)
+	}
+	if len(chainRooms) == 0 {
+		b.link(start, end)
+		chainRooms = []int{start}
+	}
+	for len(b.rooms) < rooms {
+		anchor := chainRooms[b.rng.Intn(len(chainRooms))]
+		room := b.addRoom(b.rooms[anchor].X, b.rooms[anchor].Y+1)
+		b.link(anchor, room)
+	}
+	return start, end
+}
+
+// spanAndFill links a random spanning tree of the candidate tunnels, then
+// random other candidates until target tunnels exist.
+func (b *builder) spanAndFill(candidates [][2]int, target int) {
+	b.rng.Shuffle(len(candidates), func(i, j int) { candidates[i], candidates[j] = candidates[j], candidates[i] })
+	parent := make([]int, len(b.rooms))
+	for i := range parent {
+		parent[i] = i
+	}
+	var find func(int) int
+	find = func(i int) int {
+		for parent[i] != i {
+			parent[i] = parent[parent[i]]
+			i = parent[i]
+		}
+		return i
+	}
+	var rest [][2]int
+	for _, c := range candidates {
+		ra, rb := find(c[0]), find(c[1])
+		if ra == rb {
+			rest = append(rest, c)
+			continue
+		}
+		parent[ra] = rb
+		b.link(c[0], c[1])
+	}
+	b.fill(rest, target)
+}
+
+// fill links random candidates until target tunnels exist.
+func (b *builder) fill(candidates [][2]int, target int) {
+	b.rng.Shuffle(len(candidates), func(i, j int) { candidates[i], candidates[j] = candidates[j], candidates[i] })
+	for _, c := range candidates {
+		if len(b.links) >= target {
+			return
+		}
+		b.link(c[0], c[1])
+	}
+}
+
+// components labels every room with the smallest room index of its
+// connected component.
+func (b *builder) components() []int {
+	label := make([]int, len(b.rooms))
+	for i := range label {
+		label[i] = -1
+	}
+	order := make([]int, len(b.rooms))
+	for i := range order {
+		order[i] = i
+	}
+	sort.Ints(order)
+	for _, root := range order {
+		if label[root] >= 0 {
+			continue
+		}
+		label[root] = root
+		queue := []int{root}
+		for len(queue) > 0 {
+			room := queue[0]
+			queue = queue[1:]
+			for _, next := range b.adj[room] {
+				if label[next] < 0 {
+					label[next] = root
+					queue = append(queue, next)
+				}
+			}
+		}
+	}
+	return label
+}
@@ -0,0 +1,98 @@
+package graph
+
+import (
+	"errors"
+	"sort"
+
+	"lem-in/structs"
+)
+
+// GetLowerBound computes the turns of the shortest route and the minimum cut
+// between start and end, which together bound the turns needed by antCount
+// ants from below.
+func GetLowerBound(farmGraph *structs.Graph, antCount int) (structs.LowerBound, error) {
+	startRoom, endRoom := findEndpoints(farmGraph)
+	if startRoom == "" || endRoom == "" {
+		return structs.LowerBound{}, errors.New("missing start or end room")
+	}
+	_, shortest := ShortestRoute(farmGraph, startRoom, endRoom, RouteOptions{})
+	if shortest < 0 {
+		return structs.LowerBound{}, errors.New("no paths found")
+	}
+
+	network := newFlowNetwork(farmGraph, startRoom, endRoom)
+	cutSize := 0
+	for network.augment() {
+		cutSize++
+	}
+	bound := structs.LowerBound{
+		ShortestPath: shortest,
+		CutSize:      cutSize,
+		Bottleneck:   network.minCut(),
+		Turns:        shortest + (antCount+cutSize-1)/cutSize - 1,
+	}
+	return bound, nil
+}
+
+// minCut returns, once the flow is maximal, the rooms and tunnels of a
+// minimum cut. Rooms make a clearer report, so tunnels first count as
+// unlimited while searching the residual network, except a direct
+// start-end tunnel. When that lets the search reach the end room, as when
+// a room of capacity two leads to the end through a single tunnel, the
+// tunnels are part of every minimum cut and the plain residual network is
+// searched instead. Cut tunnels are named "a-b".
+func (n *flowNetwork) minCut() []string {
+	if cut, ok := n.cut(true); ok {
+		return cut
+	}
+	cut, _ := n.cut(false)
+	return cut
+}
+
+// cut searches the residual network from the source and returns the arcs
+// leaving the reached nodes. With tunnelsUnlimited, tunnel arcs other than
+// a direct start-end tunnel are always followed; ok is false if the sink is
+// then reached.
+func (n *flowNetwork) cut(tunnelsUnlimited bool) (cut []string, ok bool) {
+	isDirect := func(node int, arc flowArc) bool {
+		return node == n.source && arc.to == n.sink
+	}
+	unlimited := func(node int, arc flowArc) bool {
+		return tunnelsUnlimited && node%2 == 1 && arc.capacity > 0 && !isDirect(node, arc)
+	}
+
+	reached := make([]bool, len(n.arcs))
+	reached[n.source] = true
+	queue := []int{n.source}
+	for len(queue) > 0 {
+		node := queue[0]
+		queue = queue[1:]
+		for _, arc := range n.arcs[node] {
+			if (unlimited(node, arc) || arc.capacity-arc.flow > 0) && !reached[arc.to] {
+				reached[arc.to] = true
+				queue = append(queue, arc.to)
+			}
+		}
+	}
+
+	if reached[n.sink] {
+		return nil, false
+	}
+	for node, arcs := range n.arcs {
+		if !reached[node] {
+			continue
+		}
+		for _, arc := range arcs {
+			if arc.capacity == 0 || reached[arc.to] {
+				continue
+			}
+			if node%2 == 1 {
+				cut = append(cut, n.rooms[node/2]+"-"+n.rooms[arc.to/2])
+			} else {
+				cut = append(cut, n.rooms[node/2])
+			}
+		}
+	}
+	sort.Strings(cut)
+	return cut, true
+}
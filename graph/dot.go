@@ -0,0 +1,83 @@
+package graph
+
+import (
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+
+	"lem-in/structs"
+)
+
+// dotColors is the palette for selected paths; it wraps around when there
+// are more paths than colors.
+var dotColors = []string{
+	"red", "green3", "blue", "orange", "purple",
+	"cyan3", "magenta", "olivedrab", "teal", "brown",
+}
+
+// WriteDOT writes the farm as an undirected Graphviz graph. Rooms keep their
+// coordinates as pinned pos attributes (for neato -n or fdp), the start and
+// end rooms get their own shapes, the tunnels of each selected path are
+// colored per path, and tunnels taking several turns are labeled.
+func WriteDOT(w io.Writer, farmGraph *structs.Graph, paths [][]string) error {
+	roomNames := make([]string, 0, len(farmGraph.Rooms))
+	for name := range farmGraph.Rooms {
+		roomNames = append(roomNames, name)
+	}
+	sort.Strings(roomNames)
+
+	// tunnel -> index of the path using it
+	pathOf := make(map[[2]string]int)
+	for i, path := range paths {
+		for j := 1; j < len(path); j++ {
+			pathOf[structs.TunnelKey(path[j-1], path[j])] = i
+		}
+	}
+
+	var b strings.Builder
+	b.WriteString("graph farm {\n")
+	b.WriteString("\tnode [shape=circle];\n")
+	for _, name := range roomNames {
+		room := farmGraph.Rooms[name]
+		attrs := []string{fmt.Sprintf("pos=\"%d,%d!\"", room.X, room.Y)}
+		switch {
+		case room.IsStart:
+			attrs = append(attrs, "shape=box", "style=filled", "fillcolor=palegreen")
+		case room.IsEnd:
+			attrs = append(attrs, "shape=doublecircle", "style=filled", "fillcolor=lightpink")
+		}
+		fmt.Fprintf(&b, "\t%s [%s];\n", dotQuote(name), strings.Join(attrs, ", "))
+	}
+
+	for _, name := range roomNames {
+		for _, next := range farmGraph.Neighbors[name] {
+			if next < name {
+				continue
+			}
+			line := fmt.Sprintf("\t%s -- %s", dotQuote(name), dotQuote(next))
+			var labels, attrs []string
+			if i, ok := pathOf[structs.TunnelKey(name, next)]; ok {
+				attrs = append(attrs, "color="+dotColors[i%len(dotColors)], "penwidth=3")
+				labels = append(labels, fmt.Sprintf("path %d", i+1))
+			}
+			if turns := farmGraph.TunnelTurns(name, next); turns > 1 {
+				labels = append(labels, fmt.Sprintf("%d turns", turns))
+			}
+			if len(labels) > 0 {
+				attrs = append(attrs, fmt.Sprintf("label=\"%s\"", strings.Join(labels, ", ")))
+				line += " [" + strings.Join(attrs, ", ") + "]"
+			}
+			b.WriteString(line + ";\n")
+		}
+	}
+	b.WriteString("}\n")
+
+	_, err := io.WriteString(w, b.String())
+	return err
+}
+
+// dotQuote quotes a room name as a DOT identifier.
+func dotQuote(name string) string {
+	return `"` + strings.NewReplacer(`\`, `\\`, `"`, `\"`).Replace(name) + `"`
+}
@@ -0,0 +1,196 @@
+package graph
+
+import (
+	"sort"
+	"strings"
+
+	"lem-in/structs"
+)
+
+// flowArc is one directed arc of the residual network.
+type flowArc struct {
+	to       int
+	rev      int
+	capacity int
+	cost     int
+	flow     int
+}
+
+// flowNetwork is the node-split residual network of a farm. Every room r
+// becomes an "in" node (2*i) and an "out" node (2*i+1) joined by an arc of
+// the room's capacity, so that a room carries at most that many paths.
+// Tunnel arcs have capacity one, so paths never share a tunnel, and cost
+// the turns an ant takes to cross them.
+type flowNetwork struct {
+	arcs      [][]flowArc
+	rooms     []string
+	source    int
+	sink      int
+	farmGraph *structs.Graph
+}
+
+// newFlowNetwork builds the node-split network for farmGraph. Rooms are
+// indexed in name order so the result does not depend on map iteration.
+func newFlowNetwork(farmGraph *structs.Graph, startRoom, endRoom string) *flowNetwork {
+	roomNames := make([]string, 0, len(farmGraph.Rooms))
+	for name := range farmGraph.Rooms {
+		roomNames = append(roomNames, name)
+	}
+	sort.Strings(roomNames)
+
+	roomIndex := make(map[string]int, len(roomNames))
+	for i, name := range roomNames {
+		roomIndex[name] = i
+	}
+
+	network := &flowNetwork{
+		arcs:      make([][]flowArc, 2*len(roomNames)),
+		rooms:     roomNames,
+		source:    2*roomIndex[startRoom] + 1,
+		sink:      2 * roomIndex[endRoom],
+		farmGraph: farmGraph,
+	}
+
+	// room capacity: as many paths as the room holds ants
+	for i, name := range roomNames {
+		capacity := farmGraph.Rooms[name].MaxAnts()
+		if name == startRoom || name == endRoom {
+			capacity = len(roomNames)
+		}
+		network.addArc(2*i, 2*i+1, capacity, 0)
+	}
+
+	// tunnels: Neighbors already lists both directions
+	for i, name := range roomNames {
+		for _, next := range farmGraph.Neighbors[name] {
+			network.addArc(2*i+1, 2*roomIndex[next], 1, farmGraph.TunnelTurns(name, next))
+		}
+	}
+	return network
+}
+
+// addArc adds an arc and its zero-capacity reverse arc.
+func (n *flowNetwork) addArc(from, to, capacity, cost int) {
+	n.arcs[from] = append(n.arcs[from], flowArc{to: to, rev: len(n.arcs[to]), capacity: capacity, cost: cost})
+	n.arcs[to] = append(n.arcs[to], flowArc{to: from, rev: len(n.arcs[from]) - 1, capacity: 0, cost: -cost})
+}
+
+// augment finds the cheapest augmenting path from source to sink with a
+// queue-based Bellman-Ford search and pushes one unit of flow along it.
+// It reports false when the sink can no longer be reached.
+func (n *flowNetwork) augment() bool {
+	const unreached = int(^uint(0) >> 1)
+
+	nodeCount := len(n.arcs)
+	dist := make([]int, nodeCount)
+	prevNode := make([]int, nodeCount)
+	prevArc := make([]int, nodeCount)
+	inQueue := make([]bool, nodeCount)
+	for i := range dist {
+		dist[i] = unreached
+	}
+
+	dist[n.source] = 0
+	queue := []int{n.source}
+	inQueue[n.source] = true
+	for len(queue) > 0 {
+		node := queue[0]
+		queue = queue[1:]
+		inQueue[node] = false
+
+		for i, arc := range n.arcs[node] {
+			if arc.capacity-arc.flow <= 0 {
+				continue
+			}
+			if dist[node]+arc.cost < dist[arc.to] {
+				dist[arc.to] = dist[node] + arc.cost
+				prevNode[arc.to] = node
+				prevArc[arc.to] = i
+				if !inQueue[arc.to] {
+					queue = append(queue, arc.to)
+					inQueue[arc.to] = true
+				}
+			}
+		}
+	}
+
+	if dist[n.sink] == unreached {
+		return false
+	}
+
+	for node := n.sink; node != n.source; node = prevNode[node] {
+		arc := &n.arcs[prevNode[node]][prevArc[node]]
+		arc.flow++
+		n.arcs[node][arc.rev].flow--
+	}
+	return true
+}
+
+// paths decomposes the current flow into room-name paths, fastest first.
+func (n *flowNetwork) paths() [][]string {
+	remaining := make([][]int, len(n.arcs))
+	for node, arcs := range n.arcs {
+		remaining[node] = make([]int, len(arcs))
+		for i, arc := range arcs {
+			if arc.capacity > 0 && arc.flow > 0 {
+				remaining[node][i] = arc.flow
+			}
+		}
+	}
+
+	// nextHop follows (and consumes) one unit of flow leaving node.
+	nextHop := func(node int) int {
+		for i, arc := range n.arcs[node] {
+			if remaining[node][i] > 0 {
+				remaining[node][i]--
+				return arc.to
+			}
+		}
+		return -1
+	}
+
+	var result [][]string
+	for {
+		node := nextHop(n.source)
+		if node < 0 {
+			break
+		}
+		route := []string{n.rooms[n.source/2]}
+		for node >= 0 && node != n.sink {
+			route = append(route, n.rooms[node/2])
+			// in(room) -> out(room) -> in(next room)
+			if node = nextHop(node); node >= 0 {
+				node = nextHop(node)
+			}
+		}
+		if node != n.sink {
+			continue
+		}
+		route = append(route, n.rooms[n.sink/2])
+		result = append(result, route)
+	}
+
+	sort.Slice(result, func(i, j int) bool {
+		turnsI, turnsJ := n.farmGraph.PathTurns(result[i]), n.farmGraph.PathTurns(result[j])
+		if turnsI != turnsJ {
+			return turnsI < turnsJ
+		}
+		if len(result[i]) != len(result[j]) {
+			return len(result[i]) < len(result[j])
+		}
+		return strings.Join(result[i], " ") < strings.Join(result[j], " ")
+	})
+	return result
+}
+
+// findPathSets runs successive shortest augmentations and records the
+// path set obtained after each one, from one path up to the
+// maximum flow.
+func findPathSets(farmGraph *structs.Graph, startRoom, endRoom string) [][][]string {
+	network := newFlowNetwork(farmGraph, startRoom, endRoom)
+	var pathSets [][][]string
+	for network.augment() {
+		pathSets = append(pathSets, network.paths())
+	}
+	return pathSets
+}
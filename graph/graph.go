@@ -1,12 +1,26 @@
 package graph
 
 import (
+	"container/heap"
 	"errors"
 	"sort"
 
+	"lem-in/scheduling"
 	"lem-in/structs"
 )
 
+// ErrTooManyRoutes is returned by GetSeparateRoutes when the farm has more
+// simple paths than it is willing to enumerate.
+var ErrTooManyRoutes = errors.New("farm too large for enumerating every path")
+
+// maxRouteSteps caps the search steps of enumerateRoutes, and maxRoutes the
+// paths it keeps, so that the exponential search gives up on large farms
+// instead of running for ever.
+const (
+	maxRouteSteps = 2000000
+	maxRoutes     = 100000
+)
+
 // BuildGraph creates a graph (map) of the ant farm using the list of rooms and tunnels.
 func BuildGraph(roomList []structs.Room, connections []structs.Tunnel) (*structs.Graph, error) {
 	graphData := &structs.Graph{
@@ -30,32 +44,111 @@ func BuildGraph(roomList []structs.Room, connections []structs.Tunnel) (*structs
 		}
 		graphData.Neighbors[t.RoomA] = append(graphData.Neighbors[t.RoomA], t.RoomB)
 		graphData.Neighbors[t.RoomB] = append(graphData.Neighbors[t.RoomB], t.RoomA)
+		if t.Turns() > 1 {
+			if graphData.Weights == nil {
+				graphData.Weights = make(map[[2]string]int)
+			}
+			graphData.Weights[structs.TunnelKey(t.RoomA, t.RoomB)] = t.Turns()
+		}
 	}
 
 	return graphData, nil
 }
 
-// GetOptimalPaths returns the maximum set of simple paths from the start room
-// to the end room, with no shared intermediate rooms.
-func GetOptimalPaths(farmGraph *structs.Graph) ([][]string, error) {
+// GetOptimalPaths returns the set of simple paths from the start room to the
+// end room, with no shared intermediate rooms, that moves antCount ants in the
+// fewest turns.
+func GetOptimalPaths(farmGraph *structs.Graph, antCount int) ([][]string, error) {
+	pathSets, err := GetCandidatePathSets(farmGraph)
+	if err != nil {
+		return nil, err
+	}
+	return BestPathSet(farmGraph, pathSets, antCount), nil
+}
+
+// BestPathSet returns the path set that moves antCount ants in the fewest
+// turns, the earliest (smallest) one on ties.
+func BestPathSet(farmGraph *structs.Graph, pathSets [][][]string, antCount int) [][]string {
+	var bestSet [][]string
+	bestTurns := -1
+	for _, pathSet := range pathSets {
+		turns := scheduling.AssignAnts(farmGraph, antCount, pathSet).PredictedTurns
+		if bestTurns < 0 || turns < bestTurns {
+			bestSet, bestTurns = pathSet, turns
+		}
+	}
+	return bestSet
+}
+
+// GetCandidatePathSets returns every disjoint path set found while the flow
+// from start to end grows, from a single shortest path up to the maximum
+// number of disjoint paths. Each set is the shortest one of its size.
+func GetCandidatePathSets(farmGraph *structs.Graph) ([][][]string, error) {
+	startRoom, endRoom := findEndpoints(farmGraph)
+	if startRoom == "" || endRoom == "" {
+		return nil, errors.New("missing start or end room")
+	}
+
+	pathSets := findPathSets(farmGraph, startRoom, endRoom)
+	if len(pathSets) == 0 {
+		return nil, errors.New("no paths found")
+	}
+	return pathSets, nil
+}
+
+// GetSeparateRoutes enumerates every simple path from start to end and picks
+// separate ones by how crowded their rooms are: no two share a tunnel and no
+// room is on more of them than it holds ants. It is exponential in the size
+// of the farm and only suited to small maps; it returns ErrTooManyRoutes
+// when the search grows too large.
+func GetSeparateRoutes(farmGraph *structs.Graph) ([][]string, error) {
 	startRoom, endRoom := findEndpoints(farmGraph)
 	if startRoom == "" || endRoom == "" {
 		return nil, errors.New("missing start or end room")
 	}
 
-	neighborMap := farmGraph.Neighbors
-	routeCandidates := enumerateRoutes(neighborMap, startRoom, endRoom)
+	routeCandidates, err := enumerateRoutes(farmGraph.Neighbors, startRoom, endRoom)
+	if err != nil {
+		return nil, err
+	}
 	if len(routeCandidates) == 0 {
 		return nil, errors.New("no paths found")
 	}
 
-	selectedRoutes := pickSeparateRoutes(routeCandidates)
+	selectedRoutes := pickSeparateRoutes(routeCandidates, farmGraph)
 	if len(selectedRoutes) == 0 {
 		return nil, errors.New("no disjoint paths found")
 	}
 	return selectedRoutes, nil
 }
 
+// UnreachableRooms returns, in name order, the rooms that no path from the
+// start room can reach.
+func UnreachableRooms(farmGraph *structs.Graph) []string {
+	startRoom, _ := findEndpoints(farmGraph)
+	reached := map[string]bool{startRoom: true}
+	queue := []string{startRoom}
+	for len(queue) > 0 {
+		room := queue[0]
+		queue = queue[1:]
+		for _, next := range farmGraph.Neighbors[room] {
+			if !reached[next] {
+				reached[next] = true
+				queue = append(queue, next)
+			}
+		}
+	}
+
+	var unreachable []string
+	for name := range farmGraph.Rooms {
+		if !reached[name] {
+			unreachable = append(unreachable, name)
+		}
+	}
+	sort.Strings(unreachable)
+	return unreachable
+}
+
 // findEndpoints locates and returns the names of the start and end rooms.
 func findEndpoints(farmGraph *structs.Graph) (string, string) {
 	var startRoom, endRoom string
@@ -71,8 +164,9 @@ func findEndpoints(farmGraph *structs.Graph) (string, string) {
 }
 
 // enumerateRoutes uses a stack-based search to find every simple path
-// from startRoom to endRoom.
-func enumerateRoutes(neighborMap map[string][]string, startRoom, endRoom string) [][]string {
+// from startRoom to endRoom. It stops with ErrTooManyRoutes past
+// maxRouteSteps steps or maxRoutes paths.
+func enumerateRoutes(neighborMap map[string][]string, startRoom, endRoom string) ([][]string, error) {
 	type stackFrame struct {
 		currentRoom string
 		nextIndex   int
@@ -85,7 +179,10 @@ func enumerateRoutes(neighborMap map[string][]string, startRoom, endRoom string)
 	currentPath := []string{startRoom}
 	stack := []stackFrame{{currentRoom: startRoom, nextIndex: 0}}
 
-	for len(stack) > 0 {
+	for steps := 0; len(stack) > 0; steps++ {
+		if steps > maxRouteSteps || len(allRoutes) > maxRoutes {
+			return nil, ErrTooManyRoutes
+		}
 		frame := &stack[len(stack)-1]
 		room := frame.currentRoom
 
@@ -122,13 +219,13 @@ func enumerateRoutes(neighborMap map[string][]string, startRoom, endRoom string)
 		stack = append(stack, stackFrame{currentRoom: nextRoom, nextIndex: 0})
 	}
 
-	return allRoutes
+	return allRoutes, nil
 }
 
 // pickSeparateRoutes scores each candidate path by how often its intermediate rooms
-// appear, then picks routes in increasing order of that score (ties by shorter length),
-// ensuring no room is used twice.
-func pickSeparateRoutes(routes [][]string) [][]string {
+// appear, then picks routes in increasing order of that score (ties by fewer turns),
+// ensuring no room is used more often than its capacity and no tunnel twice.
+func pickSeparateRoutes(routes [][]string, farmGraph *structs.Graph) [][]string {
 	// count how often each room appears in the middle of routes
 	roomCount := make(map[string]int)
 	for _, route := range routes {
@@ -152,11 +249,11 @@ func pickSeparateRoutes(routes [][]string) [][]string {
 		ranked = append(ranked, rankedRoute{
 			rooms:    route,
 			crowding: score,
-			length:   len(route),
+			length:   farmGraph.PathTurns(route),
 		})
 	}
 
-	// sort by (lower crowding) then (shorter route)
+	// sort by (lower crowding) then (faster route)
 	sort.Slice(ranked, func(i, j int) bool {
 		if ranked[i].crowding != ranked[j].crowding {
 			return ranked[i].crowding < ranked[j].crowding
@@ -164,13 +261,15 @@ func pickSeparateRoutes(routes [][]string) [][]string {
 		return ranked[i].length < ranked[j].length
 	})
 
-	// pick routes, avoiding reuse of intermediate rooms
-	usedRooms := make(map[string]bool)
+	// pick routes, avoiding full intermediate rooms and used tunnels
+	usedRooms := make(map[string]int)
+	usedTunnels := make(map[[2]string]bool)
 	var selected [][]string
 	for _, rr := range ranked {
 		ok := true
-		for _, room := range rr.rooms[1 : len(rr.rooms)-1] {
-			if usedRooms[room] {
+		for i, room := range rr.rooms[1:] {
+			if usedTunnels[structs.TunnelKey(rr.rooms[i], room)] ||
+				(i < len(rr.rooms)-2 && usedRooms[room] >= farmGraph.Rooms[room].MaxAnts()) {
 				ok = false
 				break
 			}
@@ -178,11 +277,100 @@ func pickSeparateRoutes(routes [][]string) [][]string {
 		if !ok {
 			continue
 		}
-		for _, room := range rr.rooms[1 : len(rr.rooms)-1] {
-			usedRooms[room] = true
+		for i, room := range rr.rooms[1:] {
+			usedTunnels[structs.TunnelKey(rr.rooms[i], room)] = true
+			usedRooms[room]++
 		}
 		selected = append(selected, rr.rooms)
 	}
 
 	return selected
 }
+
+// GetShortestPath returns one path from start to end that an ant walks in
+// the fewest turns. Ties go to the room reached first in tunnel order, so
+// when every tunnel takes one turn this is a breadth-first search.
+func GetShortestPath(farmGraph *structs.Graph) ([]string, error) {
+	startRoom, endRoom := findEndpoints(farmGraph)
+	if startRoom == "" || endRoom == "" {
+		return nil, errors.New("missing start or end room")
+	}
+	path, _ := ShortestRoute(farmGraph, startRoom, endRoom, RouteOptions{})
+	if path == nil {
+		return nil, errors.New("no paths found")
+	}
+	return path, nil
+}
+
+// RouteOptions restricts the rooms and tunnels ShortestRoute may use.
+type RouteOptions struct {
+	SkipStart bool                         // never enter the start room
+	Open      func(room, next string) bool // if set, whether an ant may go from room to next
+}
+
+// routeStep is a room waiting in the queue of ShortestRoute.
+type routeStep struct {
+	room  string
+	turns int
+	order int // insertion order, to break ties the same way on every run
+}
+
+// routeQueue is a min-heap of routeSteps.
+type routeQueue []routeStep
+
+func (q routeQueue) Len() int { return len(q) }
+func (q routeQueue) Less(i, j int) bool {
+	if q[i].turns != q[j].turns {
+		return q[i].turns < q[j].turns
+	}
+	return q[i].order < q[j].order
+}
+func (q routeQueue) Swap(i, j int) { q[i], q[j] = q[j], q[i] }
+func (q *routeQueue) Push(x any)   { *q = append(*q, x.(routeStep)) }
+func (q *routeQueue) Pop() (x any) { x, *q = (*q)[len(*q)-1], (*q)[:len(*q)-1]; return x }
+
+// ShortestRoute finds, with Dijkstra's algorithm over the tunnel weights,
+// the route from fromRoom to toRoom taking the fewest turns. Ties go to the
+// room reached first in tunnel order. It returns the rooms of the route,
+// fromRoom included, and its turns, or nil and -1 when there is none.
+func ShortestRoute(farmGraph *structs.Graph, fromRoom, toRoom string, opts RouteOptions) ([]string, int) {
+	turns := map[string]int{fromRoom: 0}
+	previous := make(map[string]string)
+	done := make(map[string]bool)
+	queue := &routeQueue{{room: fromRoom}}
+	order := 0
+	for queue.Len() > 0 {
+		step := heap.Pop(queue).(routeStep)
+		if done[step.room] {
+			continue
+		}
+		done[step.room] = true
+		if step.room == toRoom {
+			break
+		}
+		for _, next := range farmGraph.Neighbors[step.room] {
+			if opts.SkipStart && farmGraph.Rooms[next].IsStart {
+				continue
+			}
+			if opts.Open != nil && !opts.Open(step.room, next) {
+				continue
+			}
+			reached := step.turns + farmGraph.TunnelTurns(step.room, next)
+			if known, seen := turns[next]; seen && known <= reached {
+				continue
+			}
+			turns[next], previous[next] = reached, step.room
+			order++
+			heap.Push(queue, routeStep{room: next, turns: reached, order: order})
+		}
+	}
+	if !done[toRoom] {
+		return nil, -1
+	}
+	path := []string{toRoom}
+	for room := toRoom; room != fromRoom; {
+		room = previous[room]
+		path = append([]string{room}, path...)
+	}
+	return path, turns[toRoom]
+}
@@ -0,0 +1,159 @@
+package graph_test
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+
+	"lem-in/graph"
+	"lem-in/internal/benchfarm"
+	"lem-in/parser"
+	"lem-in/structs"
+)
+
+// sharedRoomFarm has two routes from start to end that both pass room c,
+// which holds two ants.
+const sharedRoomFarm = `2
+##start
+s 0 0
+a 1 0
+b 1 1
+##capacity 2
+c 2 0
+d 3 0
+f 3 1
+##end
+e 4 0
+s-a
+s-b
+a-c
+b-c
+c-d
+c-f
+d-e
+f-e
+`
+
+// loadGraph parses a farm and builds its graph.
+func loadGraph(t *testing.T, text string) (*structs.Graph, int) {
+	t.Helper()
+	farm, err := parser.Parse(strings.NewReader(text))
+	if err != nil {
+		t.Fatal(err)
+	}
+	g, err := graph.BuildGraph(farm.Rooms, farm.Tunnels)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return g, farm.AntCount
+}
+
+func TestSharedRoom(t *testing.T) {
+	g, antCount := loadGraph(t, sharedRoomFarm)
+	want := [][]string{{"s", "a", "c", "d", "e"}, {"s", "b", "c", "f", "e"}}
+
+	paths, err := graph.GetOptimalPaths(g, antCount)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(paths, want) {
+		t.Errorf("GetOptimalPaths: got %v, want %v", paths, want)
+	}
+
+	routes, err := graph.GetSeparateRoutes(g)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(routes) != 2 {
+		t.Errorf("GetSeparateRoutes: got %v, want two routes through c", routes)
+	}
+
+	bound, err := graph.GetLowerBound(g, antCount)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if bound.CutSize != 2 || bound.Turns != 4 {
+		t.Errorf("GetLowerBound: got a cut of %d and %d turns, want 2 and 4", bound.CutSize, bound.Turns)
+	}
+}
+
+// weightedFarm has a fast route s-b-c-e and a slow one s-a-e, whose
+// tunnel s-a takes three turns.
+const weightedFarm = `1
+##start
+s 0 0
+a 1 0
+b 1 1
+c 2 1
+##end
+e 3 0
+s-a 3
+a-e
+s-b
+b-c
+c-e
+`
+
+func TestShortestRoute(t *testing.T) {
+	g, _ := loadGraph(t, weightedFarm)
+	allButBC := func(room, next string) bool { return structs.TunnelKey(room, next) != [2]string{"b", "c"} }
+
+	tests := []struct {
+		name      string
+		from      string
+		opts      graph.RouteOptions
+		wantRoute []string
+		wantTurns int
+	}{
+		{"fewest turns", "s", graph.RouteOptions{}, []string{"s", "b", "c", "e"}, 3},
+		{"closed tunnel", "s", graph.RouteOptions{Open: allButBC}, []string{"s", "a", "e"}, 4},
+		{"back through the start", "b", graph.RouteOptions{Open: allButBC}, []string{"b", "s", "a", "e"}, 5},
+		{"start skipped", "b", graph.RouteOptions{SkipStart: true, Open: allButBC}, nil, -1},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			route, turns := graph.ShortestRoute(g, tc.from, "e", tc.opts)
+			if !reflect.DeepEqual(route, tc.wantRoute) || turns != tc.wantTurns {
+				t.Errorf("got %v in %d turns, want %v in %d", route, turns, tc.wantRoute, tc.wantTurns)
+			}
+		})
+	}
+}
+
+// TestSeparateRoutesWeighted has two routes through room m that are equally
+// crowded; the one without the slow tunnel x-e is picked.
+func TestSeparateRoutesWeighted(t *testing.T) {
+	g, _ := loadGraph(t, `1
+##start
+s 0 0
+m 1 0
+x 2 0
+y 2 1
+##end
+e 3 0
+s-m
+m-x
+m-y
+x-e 3
+y-e
+`)
+	routes, err := graph.GetSeparateRoutes(g)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := [][]string{{"s", "m", "y", "e"}}; !reflect.DeepEqual(routes, want) {
+		t.Errorf("got %v, want %v", routes, want)
+	}
+}
+
+func BenchmarkGetOptimalPaths(b *testing.B) {
+	benchfarm.Run(b, "grid", func(b *testing.B, farm *structs.Farm, g *structs.Graph) {
+		b.ReportAllocs()
+		b.ResetTimer()
+		for i := 0; i < b.N; i++ {
+			if _, err := graph.GetOptimalPaths(g, farm.AntCount); err != nil {
+				b.Fatal(err)
+			}
+		}
+	})
+}
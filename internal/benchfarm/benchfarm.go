@@ -0,0 +1,32 @@
+// Package benchfarm provides the generated farms the benchmarks run on. Only
+// tests import it, so the testing package stays out of the lem-in binary.
+package benchfarm
+
+import (
+	"testing"
+
+	"lem-in/generator"
+	"lem-in/graph"
+	"lem-in/structs"
+)
+
+// Run runs bench as a sub-benchmark of b for every generator preset, on a
+// farm of the given topology (seed 1, four tunnels per room) and its graph.
+// The farm is generated inside the sub-benchmark, so -bench filters skip it.
+func Run(b *testing.B, topology string, bench func(b *testing.B, farm *structs.Farm, g *structs.Graph)) {
+	for _, preset := range generator.Presets {
+		b.Run(preset.Name, func(b *testing.B) {
+			farm, err := generator.Generate(generator.Options{
+				Seed: 1, Rooms: preset.Rooms, Degree: 4, Ants: preset.Ants, Topology: topology,
+			})
+			if err != nil {
+				b.Fatal(err)
+			}
+			g, err := graph.BuildGraph(farm.Rooms, farm.Tunnels)
+			if err != nil {
+				b.Fatal(err)
+			}
+			bench(b, farm, g)
+		})
+	}
+}
@@ -0,0 +1,96 @@
+package parser
+
+import "fmt"
+
+// ErrorKind classifies a parse error. Every kind is itself an error, so
+// callers can test for one with errors.Is(err, parser.DuplicateRoom).
+type ErrorKind int
+
+const (
+	NoInput ErrorKind = iota + 1
+	BadAntCount
+	BadRoomName
+	DuplicateRoom
+	BadCoords
+	DuplicateCoords
+	DuplicateStart
+	DuplicateEnd
+	AdjacentDirectives
+	SelfLoop
+	UnknownRoom
+	DuplicateTunnel
+	BadLine
+	MissingStart
+	MissingEnd
+	UnreachableRoom
+	NoPath
+	BadJSON
+	BadCapacity
+	BadWeight
+)
+
+var kindMessages = map[ErrorKind]string{
+	NoInput:            "no input found",
+	BadAntCount:        "invalid ant count",
+	BadRoomName:        "invalid room name",
+	DuplicateRoom:      "duplicate room entry",
+	BadCoords:          "invalid room coordinates",
+	DuplicateCoords:    "multiple rooms with identical coordinates",
+	DuplicateStart:     "more than one start room",
+	DuplicateEnd:       "more than one end room",
+	AdjacentDirectives: "##start and ##end next to each other",
+	SelfLoop:           "tunnel connects a room with itself",
+	UnknownRoom:        "tunnel refers to a non existing room",
+	DuplicateTunnel:    "repeated tunnel",
+	BadLine:            "unrecognized line",
+	MissingStart:       "start room entry missing",
+	MissingEnd:         "end room entry missing",
+	UnreachableRoom:    "room can't be reached from the start room",
+	NoPath:             "no path between start and end",
+	BadJSON:            "invalid JSON farm",
+	BadCapacity:        "invalid room capacity",
+	BadWeight:          "invalid tunnel weight",
+}
+
+// Error returns the description of the kind.
+func (k ErrorKind) Error() string {
+	if msg, ok := kindMessages[k]; ok {
+		return msg
+	}
+	return fmt.Sprintf("parse error %d", int(k))
+}
+
+// ParseError describes an invalid line of farm input. Line and Column are
+// 1-based; they are zero when the problem is not tied to a position, as for
+// a missing start room.
+type ParseError struct {
+	Kind   ErrorKind
+	Line   int
+	Column int
+	Text   string
+}
+
+// Message returns the kind and offending text without the position.
+func (e *ParseError) Message() string {
+	if e.Text == "" {
+		return e.Kind.Error()
+	}
+	return fmt.Sprintf("%s: %q", e.Kind.Error(), e.Text)
+}
+
+// Error formats the error as "line L, column C: kind: text".
+func (e *ParseError) Error() string {
+	msg := e.Message()
+	switch {
+	case e.Line > 0 && e.Column > 0:
+		return fmt.Sprintf("line %d, column %d: %s", e.Line, e.Column, msg)
+	case e.Line > 0:
+		return fmt.Sprintf("line %d: %s", e.Line, msg)
+	}
+	return msg
+}
+
+// Unwrap exposes the kind to errors.Is.
+func (e *ParseError) Unwrap() error {
+	return e.Kind
+}
@@ -0,0 +1,102 @@
+package parser
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+
+	"lem-in/structs"
+)
+
+// jsonFarm is the JSON farm schema:
+//
+//	{
+//	  "ants": 3,
+//	  "rooms": [
+//	    {"name": "a", "x": 0, "y": 0, "role": "start"},
+//	    {"name": "b", "x": 5, "y": 0, "capacity": 2},
+//	    {"name": "c", "x": 9, "y": 0, "role": "end"}
+//	  ],
+//	  "tunnels": [{"from": "a", "to": "b"}, {"from": "b", "to": "c", "weight": 3}]
+//	}
+//
+// role is "start", "end" or omitted; capacity, like ##capacity, is the
+// number of ants the room holds at once and defaults to one. weight, like
+// "b-c 3", is the number of turns a tunnel takes to cross and defaults to
+// one. The "start" and "end" fields may name the rooms instead, as in the
+// JSON output of the run command.
+type jsonFarm struct {
+	Ants  int    `json:"ants"`
+	Start string `json:"start"`
+	End   string `json:"end"`
+	Rooms []struct {
+		Name     string `json:"name"`
+		X        int    `json:"x"`
+		Y        int    `json:"y"`
+		Role     string `json:"role"`
+		Capacity int    `json:"capacity"`
+	} `json:"rooms"`
+	Tunnels []struct {
+		From   string `json:"from"`
+		To     string `json:"to"`
+		Weight int    `json:"weight"`
+	} `json:"tunnels"`
+}
+
+// ParseJSON reads a farm in the JSON schema. It applies the same validation
+// rules as the text format and produces the same Farm.
+func ParseJSON(r io.Reader) (*structs.Farm, error) {
+	return ParseFormat(r, FormatJSON)
+}
+
+// jsonToLines decodes a JSON farm and writes it out as text-format lines.
+func jsonToLines(r io.Reader) ([]string, error) {
+	var farm jsonFarm
+	decoder := json.NewDecoder(r)
+	decoder.DisallowUnknownFields()
+	if err := decoder.Decode(&farm); err != nil {
+		return nil, &ParseError{Kind: BadJSON, Text: err.Error()}
+	}
+	// the farm must be the only value in the input
+	if err := decoder.Decode(&struct{}{}); err != io.EOF {
+		return nil, &ParseError{Kind: BadJSON, Text: "unexpected data after the farm"}
+	}
+
+	lines := []string{strconv.Itoa(farm.Ants)}
+	for i, room := range farm.Rooms {
+		role := room.Role
+		if role == "" && room.Name == farm.Start {
+			role = "start"
+		}
+		if role == "" && room.Name == farm.End {
+			role = "end"
+		}
+		switch role {
+		case "start":
+			lines = append(lines, "##start")
+		case "end":
+			lines = append(lines, "##end")
+		case "":
+		default:
+			return nil, &ParseError{Kind: BadJSON, Text: fmt.Sprintf("rooms[%d]: unknown role %q", i, room.Role)}
+		}
+		// a leading # would turn the room into a comment line
+		if strings.HasPrefix(room.Name, "#") || strings.ContainsAny(room.Name, " \t") || room.Name == "" {
+			return nil, &ParseError{Kind: BadRoomName, Text: fmt.Sprintf("rooms[%d]: %q", i, room.Name)}
+		}
+		if room.Capacity != 0 {
+			lines = append(lines, fmt.Sprintf("##capacity %d", room.Capacity))
+		}
+		lines = append(lines, fmt.Sprintf("%s %d %d", room.Name, room.X, room.Y))
+	}
+	for _, tunnel := range farm.Tunnels {
+		line := tunnel.From + "-" + tunnel.To
+		if tunnel.Weight != 0 {
+			line += fmt.Sprintf(" %d", tunnel.Weight)
+		}
+		lines = append(lines, line)
+	}
+	return lines, nil
+}
@@ -2,8 +2,8 @@ package parser
 
 import (
 	"bufio"
-	"errors"
 	"fmt"
+	"io"
 	"os"
 	"strconv"
 	"strings"
@@ -11,184 +11,399 @@ import (
 	"lem-in/structs"
 )
 
+// ParseInputFile reads the farm description stored at filePath.
 func ParseInputFile(filePath string) (int, []structs.Room, []structs.Tunnel, error) {
 	file, err := os.Open(filePath)
 	if err != nil {
 		return 0, nil, nil, fmt.Errorf("failed to open file: %v", err)
 	}
 	defer file.Close()
-	scanner := bufio.NewScanner(file)
 
-	// 1) No input at all?
-	if !scanner.Scan() {
-		return 0, nil, nil, errors.New("\nERROR: invalid data format\nNo input found...? ")
+	farm, err := Parse(file)
+	if err != nil {
+		return 0, nil, nil, err
+	}
+	return farm.AntCount, farm.Rooms, farm.Tunnels, nil
+}
+
+// Format selects the syntax of a farm description.
+type Format int
+
+const (
+	// FormatAuto detects JSON by a leading '{', text otherwise.
+	FormatAuto Format = iota
+	// FormatText is the classic "name x y" / "a-b" text format.
+	FormatText
+	// FormatJSON is the JSON farm schema (see ParseJSON).
+	FormatJSON
+)
+
+// ParseFormatName maps "auto", "text" or "json" to a Format.
+func ParseFormatName(name string) (Format, error) {
+	switch name {
+	case "auto":
+		return FormatAuto, nil
+	case "text":
+		return FormatText, nil
+	case "json":
+		return FormatJSON, nil
 	}
-	firstLine := strings.TrimSpace(scanner.Text())
-	// 2a) Invalid ant count syntax
-	antTotal, err := strconv.Atoi(firstLine)
+	return FormatAuto, fmt.Errorf("unknown input format %q", name)
+}
+
+// Parse reads a farm description (ant count, rooms, tunnels) from r, in
+// either format. It stops at the first invalid line and returns it as a
+// *ParseError.
+func Parse(r io.Reader) (*structs.Farm, error) {
+	return ParseFormat(r, FormatAuto)
+}
+
+// ParseFormat is Parse for a given input format.
+func ParseFormat(r io.Reader, format Format) (*structs.Farm, error) {
+	lines, positioned, err := readSource(r, format)
 	if err != nil {
-		return 0, nil, nil, errors.New("\nERROR: invalid data format\nInvalid number in ant count entry")
-	}
-	// 2b) Non-positive ant count
-	if antTotal <= 0 {
-		return 0, nil, nil, errors.New("\nERROR: invalid data format\nInvalid ant number value given")
-	}
-
-	var (
-		rooms          []structs.Room
-		tunnels        []structs.Tunnel
-		seenNames      = make(map[string]bool)
-		seenCoords     = make(map[string]bool) // "x,y"
-		seenTunnels    = make(map[string]bool) // "A-B" sorted
-		startDirCount  int
-		endDirCount    int
-		startRoomCount int
-		endRoomCount   int
-		nextIsStart    bool
-		nextIsEnd      bool
-		prevWasDir     string // "start" or "end" or ""
-	)
+		return nil, err
+	}
+	reader := newFarmReader(positioned)
+	for _, line := range lines {
+		if perr := reader.readLine(line); perr != nil {
+			return nil, perr
+		}
+	}
+	if perrs := reader.finish(); len(perrs) > 0 {
+		return nil, perrs[0]
+	}
+	return reader.farm, nil
+}
 
+// ParseAll reads a farm description like ParseFormat, but keeps going after
+// invalid lines and returns every problem found, in input order. The farm
+// holds whatever could be read.
+func ParseAll(r io.Reader, format Format) (*structs.Farm, []*ParseError, error) {
+	lines, positioned, err := readSource(r, format)
+	if err != nil {
+		return nil, nil, err
+	}
+	reader := newFarmReader(positioned)
+	var perrs []*ParseError
+	for _, line := range lines {
+		if perr := reader.readLine(line); perr != nil {
+			perrs = append(perrs, perr)
+		}
+	}
+	perrs = append(perrs, reader.finish()...)
+	return reader.farm, perrs, nil
+}
+
+// readSource returns the input as text-format lines. JSON input is
+// translated line by line, so both formats share every validation rule;
+// positioned is false for it since line numbers would not match the input.
+func readSource(r io.Reader, format Format) (lines []string, positioned bool, err error) {
+	buffered := bufio.NewReader(r)
+	if format == FormatAuto {
+		format = FormatText
+		if looksLikeJSON(buffered) {
+			format = FormatJSON
+		}
+	}
+	if format == FormatJSON {
+		lines, err = jsonToLines(buffered)
+		return lines, false, err
+	}
+
+	scanner := bufio.NewScanner(buffered)
 	for scanner.Scan() {
-		line := strings.TrimSpace(scanner.Text())
-		if line == "" {
-			continue
+		lines = append(lines, scanner.Text())
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, false, fmt.Errorf("failed to read input: %v", err)
+	}
+	return lines, true, nil
+}
+
+// looksLikeJSON reports whether the first non-blank byte of r is '{'.
+func looksLikeJSON(r *bufio.Reader) bool {
+	for n := 1; ; n++ {
+		peeked, err := r.Peek(n)
+		if len(peeked) < n {
+			return false
 		}
-		// Comments / directives
-		if strings.HasPrefix(line, "#") {
-			if line == "##start" {
-				startDirCount++
-				if startDirCount > 1 {
-					return 0, nil, nil, errors.New("\nERROR: invalid data format\nOnly use ##start once please")
-				}
-				if prevWasDir == "end" {
-					return 0, nil, nil, errors.New("\nERROR: invalid data format\nDon't put ##end ##start next to eachother")
-				}
-				nextIsStart = true
-				prevWasDir = "start"
-				continue
+		switch peeked[n-1] {
+		case ' ', '\t', '\r', '\n':
+			if err != nil {
+				return false
 			}
-			if line == "##end" {
-				endDirCount++
-				if endDirCount > 1 {
-					return 0, nil, nil, errors.New("\nERROR: invalid data format\nOnly use ##end once please")
-				}
-				if prevWasDir == "start" {
-					return 0, nil, nil, errors.New("\nERROR: invalid data format\nDon't put ##end ##start next to eachother")
-				}
-				nextIsEnd = true
-				prevWasDir = "end"
-				continue
-			}
-			// ordinary comment
-			prevWasDir = ""
 			continue
+		case '{':
+			return true
 		}
+		return false
+	}
+}
 
-		// Room definition?
-		parts := strings.Fields(line)
-		if len(parts) == 3 {
-			name, xs, ys := parts[0], parts[1], parts[2]
-			// 4) Name must not start with 'L'
-			if strings.HasPrefix(name, "L") {
-				return 0, nil, nil, fmt.Errorf(
-					"\nERROR: invalid data format\nRoom names can't start with L, at this line: %s", line)
-			}
-			// 5) Duplicate room name?
-			if seenNames[name] {
-				return 0, nil, nil, fmt.Errorf(
-					"\nERROR: invalid data format\nDuplicate room entry found, at this line: %s", line)
-			}
-			seenNames[name] = true
-
-			// 6) Parse coordinates
-			x, errX := strconv.Atoi(xs)
-			y, errY := strconv.Atoi(ys)
-			if errX != nil || errY != nil {
-				return 0, nil, nil, fmt.Errorf(
-					"\nERROR: invalid data format\nInvalid room coord number, at this line: %s", line)
-			}
-			coordKey := fmt.Sprintf("%d,%d", x, y)
-			// 7) Duplicate coordinates?
-			if seenCoords[coordKey] {
-				return 0, nil, nil, fmt.Errorf(
-					"\nERROR: invalid data format\nFound multiple rooms with identical coordinates, at this line: %s", line)
-			}
-			seenCoords[coordKey] = true
-
-			// Build and append
-			rooms = append(rooms, structs.Room{
-				Name:    name,
-				X:       x,
-				Y:       y,
-				IsStart: nextIsStart,
-				IsEnd:   nextIsEnd,
-			})
-			if nextIsStart {
-				startRoomCount++
-				if startRoomCount > 1 {
-					return 0, nil, nil, errors.New(
-						"\nERROR: invalid data format\nMultiple start rooms are not allowed")
-				}
-			}
-			if nextIsEnd {
-				endRoomCount++
-				if endRoomCount > 1 {
-					return 0, nil, nil, errors.New(
-						"\nERROR: invalid data format\nMultiple end rooms are not allowed")
-				}
-			}
+// farmReader holds the state of a line-by-line parse.
+type farmReader struct {
+	farm          *structs.Farm
+	positioned    bool // report line and column numbers
+	lineNo        int
+	gotAntCount   bool
+	seenNames     map[string]bool
+	seenCoords    map[string]bool // "x,y"
+	seenTunnels   map[string]bool // "A-B"
+	startDirCount int
+	endDirCount   int
+	nextIsStart   bool
+	nextIsEnd     bool
+	nextCapacity  int    // from a ##capacity directive, 0 if none
+	prevWasDir    string // "start" or "end" or ""
+}
 
-			// reset flags
-			nextIsStart = false
-			nextIsEnd = false
-			prevWasDir = ""
-			continue
+func newFarmReader(positioned bool) *farmReader {
+	return &farmReader{
+		farm:        &structs.Farm{},
+		positioned:  positioned,
+		seenNames:   make(map[string]bool),
+		seenCoords:  make(map[string]bool),
+		seenTunnels: make(map[string]bool),
+	}
+}
+
+// fail builds a ParseError for the current line, pointing at the field with
+// the given index (or at the first character when field is negative).
+func (fr *farmReader) fail(kind ErrorKind, raw string, field int) *ParseError {
+	column := strings.Index(raw, strings.TrimSpace(raw)) + 1
+	if field >= 0 {
+		if offsets := fieldOffsets(raw); field < len(offsets) {
+			column = offsets[field] + 1
 		}
+	}
+	if !fr.positioned {
+		return &ParseError{Kind: kind, Text: strings.TrimSpace(raw)}
+	}
+	return &ParseError{Kind: kind, Line: fr.lineNo, Column: column, Text: strings.TrimSpace(raw)}
+}
 
-		// Tunnel definition?
-		if strings.Contains(line, "-") {
-			pair := strings.Split(line, "-")
-			if len(pair) != 2 {
-				return 0, nil, nil, errors.New(
-					"\nERROR: invalid data format\nSomething invalid in a line of input...? at this line: " + line)
-			}
-			a, b := pair[0], pair[1]
-			// 8) Self-loop?
-			if a == b {
-				return 0, nil, nil, fmt.Errorf(
-					"\nERROR: invalid data format\nCan't connect a room with itself, at this line: %s", line)
-			}
-			// 9) Both rooms must already exist:
-			if !seenNames[a] || !seenNames[b] {
-				return 0, nil, nil, fmt.Errorf(
-					"\nERROR: invalid data format\nConnection referenced a non existing room, at this line: %s", line)
-			}
-			// 10) Duplicate tunnel? (order-independent)
-			key1, key2 := a+"-"+b, b+"-"+a
-			if seenTunnels[key1] || seenTunnels[key2] {
-				return 0, nil, nil, fmt.Errorf(
-					"\nERROR: invalid data format\nRepeated connection found, at this line: %s", line)
-			}
-			seenTunnels[key1] = true
+// line returns the current line number, or 0 when positions are not reported.
+func (fr *farmReader) line() int {
+	if !fr.positioned {
+		return 0
+	}
+	return fr.lineNo
+}
 
-			tunnels = append(tunnels, structs.Tunnel{RoomA: a, RoomB: b})
-			prevWasDir = ""
-			continue
+// readLine consumes one line of input.
+func (fr *farmReader) readLine(raw string) *ParseError {
+	fr.lineNo++
+	line := strings.TrimSpace(raw)
+	if line != "" {
+		fr.farm.Lines = append(fr.farm.Lines, raw)
+	}
+
+	// 1) First line holds the ant count
+	if !fr.gotAntCount {
+		fr.gotAntCount = true
+		antTotal, err := strconv.Atoi(line)
+		// 2) Invalid syntax or non-positive ant count
+		if err != nil || antTotal <= 0 {
+			return fr.fail(BadAntCount, raw, -1)
 		}
+		fr.farm.AntCount = antTotal
+		return nil
+	}
 
-		// Anything else is not valid
-		return 0, nil, nil, fmt.Errorf(
-			"\nERROR: invalid data format\nSomething invalid in a line of input...? at this line: %s", line)
+	if line == "" {
+		return nil
+	}
+	// 3) Comments / directives
+	if strings.HasPrefix(line, "#") {
+		return fr.readComment(raw, line)
 	}
 
-	// 11) Make sure we actually got one start and one end
-	if startRoomCount == 0 {
-		return 0, nil, nil, errors.New("\nERROR: invalid data format\nStart room entry missing")
+	parts := strings.Fields(line)
+	if len(parts) == 3 {
+		return fr.readRoom(raw, parts)
 	}
-	if endRoomCount == 0 {
-		return 0, nil, nil, errors.New("\nERROR: invalid data format\nEnd room entry missing")
+	if len(parts) <= 2 && strings.Contains(parts[0], "-") {
+		return fr.readTunnel(raw, parts)
 	}
 
-	return antTotal, rooms, tunnels, nil
+	// Anything else is not valid
+	fr.prevWasDir = ""
+	return fr.fail(BadLine, raw, -1)
+}
+
+func (fr *farmReader) readComment(raw, line string) *ParseError {
+	switch line {
+	case "##start":
+		fr.startDirCount++
+		if fr.startDirCount > 1 {
+			return fr.fail(DuplicateStart, raw, -1)
+		}
+		if fr.prevWasDir == "end" {
+			return fr.fail(AdjacentDirectives, raw, -1)
+		}
+		fr.nextIsStart = true
+		fr.prevWasDir = "start"
+	case "##end":
+		fr.endDirCount++
+		if fr.endDirCount > 1 {
+			return fr.fail(DuplicateEnd, raw, -1)
+		}
+		if fr.prevWasDir == "start" {
+			return fr.fail(AdjacentDirectives, raw, -1)
+		}
+		fr.nextIsEnd = true
+		fr.prevWasDir = "end"
+	default:
+		if fields := strings.Fields(line); fields[0] == "##capacity" {
+			return fr.readCapacity(raw, fields)
+		}
+		// ordinary comment
+		fr.farm.Comments = append(fr.farm.Comments, line)
+		fr.prevWasDir = ""
+	}
+	return nil
+}
+
+// readCapacity reads a "##capacity N" directive, which sets how many ants
+// the next room holds at once.
+func (fr *farmReader) readCapacity(raw string, fields []string) *ParseError {
+	if len(fields) != 2 {
+		return fr.fail(BadCapacity, raw, -1)
+	}
+	capacity, err := strconv.Atoi(fields[1])
+	if err != nil || capacity <= 0 {
+		return fr.fail(BadCapacity, raw, 1)
+	}
+	fr.nextCapacity = capacity
+	return nil
+}
+
+func (fr *farmReader) readRoom(raw string, parts []string) *ParseError {
+	name, xs, ys := parts[0], parts[1], parts[2]
+	isStart, isEnd, capacity := fr.nextIsStart, fr.nextIsEnd, fr.nextCapacity
+	fr.nextIsStart, fr.nextIsEnd, fr.nextCapacity, fr.prevWasDir = false, false, 0, ""
+
+	// 4) Name must not start with 'L'
+	if strings.HasPrefix(name, "L") {
+		return fr.fail(BadRoomName, raw, 0)
+	}
+	// 5) Duplicate room name?
+	if fr.seenNames[name] {
+		return fr.fail(DuplicateRoom, raw, 0)
+	}
+	fr.seenNames[name] = true
+
+	// 6) Parse coordinates
+	x, errX := strconv.Atoi(xs)
+	if errX != nil {
+		return fr.fail(BadCoords, raw, 1)
+	}
+	y, errY := strconv.Atoi(ys)
+	if errY != nil {
+		return fr.fail(BadCoords, raw, 2)
+	}
+	// 7) Duplicate coordinates?
+	coordKey := fmt.Sprintf("%d,%d", x, y)
+	if fr.seenCoords[coordKey] {
+		return fr.fail(DuplicateCoords, raw, 1)
+	}
+	fr.seenCoords[coordKey] = true
+
+	// 8) Only one start and one end room
+	if isStart {
+		if fr.farm.Start != "" {
+			return fr.fail(DuplicateStart, raw, 0)
+		}
+		fr.farm.Start = name
+	}
+	if isEnd {
+		if fr.farm.End != "" {
+			return fr.fail(DuplicateEnd, raw, 0)
+		}
+		fr.farm.End = name
+	}
+
+	fr.farm.Rooms = append(fr.farm.Rooms, structs.Room{
+		Name:     name,
+		X:        x,
+		Y:        y,
+		IsStart:  isStart,
+		IsEnd:    isEnd,
+		Capacity: capacity,
+		Line:     fr.line(),
+	})
+	return nil
+}
+
+// readTunnel reads "a-b", or "a-b N" for a tunnel that takes N turns to
+// cross.
+func (fr *farmReader) readTunnel(raw string, parts []string) *ParseError {
+	fr.prevWasDir = ""
+	pair := strings.Split(parts[0], "-")
+	if len(pair) != 2 {
+		return fr.fail(BadLine, raw, -1)
+	}
+	a, b := pair[0], pair[1]
+	// 9) Self-loop?
+	if a == b {
+		return fr.fail(SelfLoop, raw, -1)
+	}
+	// 10) Both rooms must already exist
+	if !fr.seenNames[a] {
+		return fr.fail(UnknownRoom, raw, -1)
+	}
+	if !fr.seenNames[b] {
+		perr := fr.fail(UnknownRoom, raw, -1)
+		if perr.Column > 0 {
+			perr.Column += len(a) + 1
+		}
+		return perr
+	}
+	// 11) Duplicate tunnel? (order-independent)
+	if fr.seenTunnels[a+"-"+b] || fr.seenTunnels[b+"-"+a] {
+		return fr.fail(DuplicateTunnel, raw, -1)
+	}
+	fr.seenTunnels[a+"-"+b] = true
+
+	weight := 0
+	if len(parts) == 2 {
+		var err error
+		weight, err = strconv.Atoi(parts[1])
+		if err != nil || weight <= 0 {
+			return fr.fail(BadWeight, raw, 1)
+		}
+	}
+	fr.farm.Tunnels = append(fr.farm.Tunnels, structs.Tunnel{RoomA: a, RoomB: b, Weight: weight})
+	return nil
+}
+
+// finish reports problems that can only be detected at end of input.
+func (fr *farmReader) finish() []*ParseError {
+	if !fr.gotAntCount {
+		return []*ParseError{{Kind: NoInput}}
+	}
+	// 12) Make sure we actually got one start and one end
+	var perrs []*ParseError
+	if fr.farm.Start == "" {
+		perrs = append(perrs, &ParseError{Kind: MissingStart, Line: fr.line()})
+	}
+	if fr.farm.End == "" {
+		perrs = append(perrs, &ParseError{Kind: MissingEnd, Line: fr.line()})
+	}
+	return perrs
+}
+
+// fieldOffsets returns the byte offset of each whitespace-separated field.
+func fieldOffsets(raw string) []int {
+	var offsets []int
+	inField := false
+	for i, r := range raw {
+		isSpace := r == ' ' || r == '\t'
+		if !isSpace && !inField {
+			offsets = append(offsets, i)
+		}
+		inField = !isSpace
+	}
+	return offsets
 }
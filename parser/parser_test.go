@@ -0,0 +1,75 @@
+package parser_test
+
+import (
+	"bytes"
+	"errors"
+	"strings"
+	"testing"
+
+	"lem-in/internal/benchfarm"
+	"lem-in/parser"
+	"lem-in/structs"
+	"lem-in/visualizer"
+)
+
+func TestParseJSON(t *testing.T) {
+	farm, err := parser.Parse(strings.NewReader(`{
+		"ants": 3,
+		"rooms": [
+			{"name": "s", "x": 0, "y": 0, "role": "start"},
+			{"name": "m", "x": 1, "y": 0, "capacity": 2},
+			{"name": "e", "x": 2, "y": 0, "role": "end"}
+		],
+		"tunnels": [{"from": "s", "to": "m"}, {"from": "m", "to": "e", "weight": 3}]
+	}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if farm.AntCount != 3 || farm.Start != "s" || farm.End != "e" {
+		t.Errorf("got %d ants from %s to %s, want 3 ants from s to e", farm.AntCount, farm.Start, farm.End)
+	}
+	if len(farm.Rooms) != 3 || len(farm.Tunnels) != 2 {
+		t.Errorf("got %d rooms and %d tunnels, want 3 and 2", len(farm.Rooms), len(farm.Tunnels))
+	}
+
+	if farm.Rooms[1].MaxAnts() != 2 {
+		t.Errorf("room m holds %d ants, want 2", farm.Rooms[1].MaxAnts())
+	}
+	if farm.Tunnels[1].Turns() != 3 {
+		t.Errorf("tunnel m-e takes %d turns, want 3", farm.Tunnels[1].Turns())
+	}
+
+	_, err = parser.Parse(strings.NewReader(`{"ants": 3, "rooms": [{"name": "m", "capacity": -1}]}`))
+	if !errors.Is(err, parser.BadCapacity) {
+		t.Errorf("negative capacity: got error %v, want %q", err, parser.BadCapacity)
+	}
+
+	for _, input := range []string{
+		`{"ants": 3, "rooms": [{"name": "s", "role": "middle"}]}`,
+		`{"ants": 3, "colour": "red"}`,
+		`{"ants": 3} trailing data`,
+	} {
+		_, err := parser.Parse(strings.NewReader(input))
+		var parseErr *parser.ParseError
+		if !errors.As(err, &parseErr) || !errors.Is(err, parser.BadJSON) {
+			t.Errorf("%s: got error %v, want a parse error %q", input, err, parser.BadJSON)
+		}
+	}
+}
+
+func BenchmarkParse(b *testing.B) {
+	benchfarm.Run(b, "grid", func(b *testing.B, farm *structs.Farm, _ *structs.Graph) {
+		var input bytes.Buffer
+		if err := visualizer.WriteFarm(&input, farm); err != nil {
+			b.Fatal(err)
+		}
+		b.SetBytes(int64(input.Len()))
+		b.ReportAllocs()
+		b.ResetTimer()
+		for i := 0; i < b.N; i++ {
+			if _, err := parser.Parse(bytes.NewReader(input.Bytes())); err != nil {
+				b.Fatal(err)
+			}
+		}
+	})
+}
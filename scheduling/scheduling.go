@@ -6,25 +6,75 @@ import (
 	"lem-in/structs"
 )
 
-// AssignAnts distributes ants among paths by minimizing cost = len+assigned-1.
-func AssignAnts(antCount int, paths [][]string) structs.PathAssignment {
+// AssignAnts distributes ants among paths by minimizing cost = len+assigned-1,
+// where len is one more than the turns an ant takes to walk the path (its
+// number of rooms when every tunnel takes one turn). Each ant goes to the
+// path with the lowest cost (lowest index on ties), which amounts to filling
+// paths up to a common level; that level is found in one pass over the
+// paths sorted by length.
+func AssignAnts(farmGraph *structs.Graph, antCount int, paths [][]string) structs.PathAssignment {
 	numPaths := len(paths)
 	antsPerPath := make([]int, numPaths)
+	assignment := structs.PathAssignment{Paths: paths, AntsPerPath: antsPerPath}
+	if numPaths == 0 || antCount <= 0 {
+		return assignment
+	}
+
+	lengths := make([]int, numPaths)
+	for i, path := range paths {
+		lengths[i] = farmGraph.PathTurns(path) + 1
+	}
+	sorted := append([]int(nil), lengths...)
+	sort.Ints(sorted)
 
-	for i := 0; i < antCount; i++ {
-		type pathCost struct {
-			index int
-			cost  int
+	// level: highest cost L such that the slots below it on the k shortest
+	// paths, k*L - sum(lengths), still fit antCount ants
+	level, lengthSum := 0, 0
+	for k := 1; k <= numPaths; k++ {
+		lengthSum += sorted[k-1]
+		level = (antCount + lengthSum) / k
+		if k == numPaths || level <= sorted[k] {
+			break
 		}
-		pathCosts := make([]pathCost, numPaths)
-		for j := 0; j < numPaths; j++ {
-			pathCosts[j] = pathCost{index: j, cost: len(paths[j]) + antsPerPath[j] - 1}
+	}
+
+	remaining := antCount
+	for i, length := range lengths {
+		if length < level {
+			antsPerPath[i] = level - length
+			remaining -= antsPerPath[i]
 		}
-		sort.Slice(pathCosts, func(a, b int) bool {
-			return pathCosts[a].cost < pathCosts[b].cost
-		})
-		antsPerPath[pathCosts[0].index]++
 	}
+	// leftover ants take the slot at the level itself, lowest index first
+	for i, length := range lengths {
+		if remaining == 0 {
+			break
+		}
+		if length <= level {
+			antsPerPath[i]++
+			remaining--
+		}
+	}
+
+	assignment.PredictedTurns = PredictTurns(farmGraph, assignment)
+	return assignment
+}
 
-	return structs.PathAssignment{Paths: paths, AntsPerPath: antsPerPath}
+// PredictTurns returns the number of turns needed to move every ant along
+// its assigned path: max over used paths of the turns to walk it plus
+// assigned-1, since one ant enters every tunnel per turn. Paths may share
+// a room that holds several ants but never a tunnel, and each path has at
+// most one ant in a room at a time, so ants on different paths never hold
+// each other up.
+func PredictTurns(farmGraph *structs.Graph, assignment structs.PathAssignment) int {
+	turns := 0
+	for i, path := range assignment.Paths {
+		if assignment.AntsPerPath[i] == 0 {
+			continue
+		}
+		if cost := farmGraph.PathTurns(path) + assignment.AntsPerPath[i] - 1; cost > turns {
+			turns = cost
+		}
+	}
+	return turns
 }
@@ -0,0 +1,75 @@
+package scheduling_test
+
+import (
+	"strings"
+	"testing"
+
+	"lem-in/graph"
+	"lem-in/internal/benchfarm"
+	"lem-in/parser"
+	"lem-in/scheduling"
+	"lem-in/simulation"
+	"lem-in/structs"
+)
+
+// TestSharedRoom checks the prediction for two paths through room c, which
+// holds two ants, against the simulation.
+func TestSharedRoom(t *testing.T) {
+	farm, err := parser.Parse(strings.NewReader(`5
+##start
+s 0 0
+a 1 0
+b 1 1
+##capacity 2
+c 2 0
+d 3 0
+f 3 1
+##end
+e 4 0
+s-a
+s-b
+a-c
+b-c
+c-d
+c-f
+d-e
+f-e
+`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	g, err := graph.BuildGraph(farm.Rooms, farm.Tunnels)
+	if err != nil {
+		t.Fatal(err)
+	}
+	paths := [][]string{{"s", "a", "c", "d", "e"}, {"s", "b", "c", "f", "e"}}
+
+	assignment := scheduling.AssignAnts(g, farm.AntCount, paths)
+	if assignment.AntsPerPath[0] != 3 || assignment.AntsPerPath[1] != 2 {
+		t.Errorf("got %v ants per path, want [3 2]", assignment.AntsPerPath)
+	}
+	if assignment.PredictedTurns != 6 {
+		t.Errorf("predicted %d turns, want 6", assignment.PredictedTurns)
+	}
+	turns, err := simulation.Run(g, paths, assignment, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if turns != assignment.PredictedTurns {
+		t.Errorf("simulated %d turns, predicted %d", turns, assignment.PredictedTurns)
+	}
+}
+
+func BenchmarkAssignAnts(b *testing.B) {
+	benchfarm.Run(b, "layered", func(b *testing.B, farm *structs.Farm, g *structs.Graph) {
+		paths, err := graph.GetOptimalPaths(g, farm.AntCount)
+		if err != nil {
+			b.Fatal(err)
+		}
+		b.ReportAllocs()
+		b.ResetTimer()
+		for i := 0; i < b.N; i++ {
+			scheduling.AssignAnts(g, farm.AntCount, paths)
+		}
+	})
+}
@@ -0,0 +1,452 @@
+package simulation
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"sort"
+	"strconv"
+	"strings"
+
+	"lem-in/graph"
+	"lem-in/structs"
+)
+
+// Event closes or opens a room or a tunnel at the start of a turn. Tunnel
+// events name both rooms; room events leave RoomB empty.
+type Event struct {
+	Turn  int
+	Open  bool
+	RoomA string
+	RoomB string
+	Line  int
+}
+
+// IsTunnel reports whether the event targets a tunnel.
+func (e Event) IsTunnel() bool {
+	return e.RoomB != ""
+}
+
+func (e Event) String() string {
+	action := "close"
+	if e.Open {
+		action = "open"
+	}
+	if e.IsTunnel() {
+		return fmt.Sprintf("%s %s-%s", action, e.RoomA, e.RoomB)
+	}
+	return fmt.Sprintf("%s %s", action, e.RoomA)
+}
+
+// Planner picks the paths for antCount ants waiting in the start room of
+// farmGraph.
+type Planner func(farmGraph *structs.Graph, antCount int) (structs.PathAssignment, error)
+
+// ParseEvents reads an event script: one "<turn> close|open <room>|<a-b>"
+// per line. Blank lines and lines starting with "#" are skipped. Events are
+// returned in turn order, in script order within a turn.
+func ParseEvents(r io.Reader) ([]Event, error) {
+	var events []Event
+	scanner := bufio.NewScanner(r)
+	lineNo := 0
+	for scanner.Scan() {
+		lineNo++
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		fields := strings.Fields(line)
+		if len(fields) != 3 {
+			return nil, fmt.Errorf("events line %d: want \"<turn> close|open <room or a-b>\": %q", lineNo, line)
+		}
+		turn, err := strconv.Atoi(fields[0])
+		if err != nil || turn < 1 {
+			return nil, fmt.Errorf("events line %d: invalid turn %q", lineNo, fields[0])
+		}
+		event := Event{Turn: turn, Line: lineNo}
+		switch fields[1] {
+		case "open":
+			event.Open = true
+		case "close":
+		default:
+			return nil, fmt.Errorf("events line %d: unknown action %q", lineNo, fields[1])
+		}
+		event.RoomA = fields[2]
+		if a, b, ok := strings.Cut(fields[2], "-"); ok {
+			event.RoomA, event.RoomB = a, b
+		}
+		events = append(events, event)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read events: %v", err)
+	}
+	sort.SliceStable(events, func(i, j int) bool { return events[i].Turn < events[j].Turn })
+	return events, nil
+}
+
+// CheckEvents reports the first event that names an unknown room or tunnel
+// or would close the start or end room.
+func CheckEvents(farmGraph *structs.Graph, events []Event) error {
+	for _, event := range events {
+		fail := func(reason string) error {
+			return fmt.Errorf("events line %d: %s: %s", event.Line, event, reason)
+		}
+		room, ok := farmGraph.Rooms[event.RoomA]
+		if !ok {
+			return fail("unknown room " + event.RoomA)
+		}
+		if !event.IsTunnel() {
+			if room.IsStart || room.IsEnd {
+				return fail("the start and end rooms cannot be closed")
+			}
+			continue
+		}
+		if _, ok := farmGraph.Rooms[event.RoomB]; !ok {
+			return fail("unknown room " + event.RoomB)
+		}
+		connected := false
+		for _, next := range farmGraph.Neighbors[event.RoomA] {
+			connected = connected || next == event.RoomB
+		}
+		if !connected {
+			return fail("no such tunnel")
+		}
+	}
+	return nil
+}
+
+// dynamicAnt is an ant that has left the start room. An ant with a transit
+// above zero is inside the tunnel from room to route[0] and comes out after
+// that many more turns.
+type dynamicAnt struct {
+	id      int
+	room    string
+	route   []string // rooms still to visit, short of the end room when no open route leads there
+	transit int
+}
+
+// launchPath is a planned path and the number of ants still to send on it.
+type launchPath struct {
+	path      []string
+	remaining int
+}
+
+// dynamicFarm is the state of a simulation whose farm changes over time.
+type dynamicFarm struct {
+	graph         *structs.Graph
+	start, end    string
+	closedRooms   map[string]bool
+	closedTunnels map[[2]string]bool
+	ants          []*dynamicAnt
+	launches      []launchPath
+	waiting       int
+	nextID        int
+	occupancy     map[string]int
+	capacity      map[string]int
+}
+
+// SimulateEvents runs the simulation while events close and open rooms and
+// tunnels. Ants in the start room are planned with plan on the farm as it
+// is at turn 1 and again after every turn with events; ants already on the
+// way keep their route while it stays open and otherwise take a fastest
+// open route from where they are. Closed rooms may be left but not entered;
+// an ant already inside a tunnel finishes crossing it.
+// Every re-plan is logged in the Replans of the result. Ants are numbered
+// in the order they leave the start room.
+func SimulateEvents(farmGraph *structs.Graph, antCount int, events []Event, plan Planner) (structs.SimResult, error) {
+	df := &dynamicFarm{
+		graph:         farmGraph,
+		closedRooms:   make(map[string]bool),
+		closedTunnels: make(map[[2]string]bool),
+		waiting:       antCount,
+		nextID:        1,
+		occupancy:     make(map[string]int),
+		capacity:      roomCapacities(farmGraph),
+	}
+	for name, room := range farmGraph.Rooms {
+		if room.IsStart {
+			df.start = name
+		}
+		if room.IsEnd {
+			df.end = name
+		}
+	}
+
+	var result structs.SimResult
+	next := 0
+	for turn := 1; df.waiting > 0 || len(df.ants) > 0; turn++ {
+		var applied []string
+		for ; next < len(events) && events[next].Turn == turn; next++ {
+			df.apply(events[next])
+			applied = append(applied, events[next].String())
+		}
+		if turn == 1 || len(applied) > 0 {
+			log := df.replan(plan)
+			if len(applied) > 0 {
+				log = append([]string{strings.Join(applied, ", ")}, log...)
+			}
+			for _, entry := range log {
+				result.Replans = append(result.Replans, fmt.Sprintf("turn %d: %s", turn, entry))
+			}
+		}
+
+		moves := df.step()
+		if len(moves) == 0 && !df.inTransit() && next == len(events) {
+			return result, fmt.Errorf("turn %d: %d ant(s) can no longer reach the end room",
+				turn, df.waiting+len(df.ants))
+		}
+		result.Turns = append(result.Turns, moves)
+		result.Grids = append(result.Grids, df.grid())
+		result.States = append(result.States, df.turnState())
+	}
+	return result, nil
+}
+
+// apply records one event.
+func (df *dynamicFarm) apply(event Event) {
+	if event.IsTunnel() {
+		df.closedTunnels[structs.TunnelKey(event.RoomA, event.RoomB)] = !event.Open
+	} else {
+		df.closedRooms[event.RoomA] = !event.Open
+	}
+}
+
+// isOpen reports whether an ant may go from room to next.
+func (df *dynamicFarm) isOpen(room, next string) bool {
+	return !df.closedRooms[next] && !df.closedTunnels[structs.TunnelKey(room, next)]
+}
+
+// openGraph returns the farm without its closed rooms and tunnels.
+func (df *dynamicFarm) openGraph() *structs.Graph {
+	g := &structs.Graph{
+		Rooms:     make(map[string]*structs.Room, len(df.graph.Rooms)),
+		Neighbors: make(map[string][]string, len(df.graph.Neighbors)),
+		Weights:   df.graph.Weights,
+	}
+	for name, room := range df.graph.Rooms {
+		if !df.closedRooms[name] {
+			g.Rooms[name] = room
+		}
+	}
+	for name, neighbors := range df.graph.Neighbors {
+		if df.closedRooms[name] {
+			continue
+		}
+		for _, next := range neighbors {
+			if df.isOpen(name, next) {
+				g.Neighbors[name] = append(g.Neighbors[name], next)
+			}
+		}
+	}
+	return g
+}
+
+// replan plans the waiting ants on the open farm and re-routes the ants on
+// the way whose route was closed. It returns a log of what changed.
+func (df *dynamicFarm) replan(plan Planner) []string {
+	var log []string
+	open := df.openGraph()
+
+	df.launches = nil
+	if df.waiting > 0 {
+		assignment, err := plan(open, df.waiting)
+		if err != nil || len(assignment.Paths) == 0 {
+			log = append(log, fmt.Sprintf("no open path for the %d waiting ant(s)", df.waiting))
+		} else {
+			var paths []string
+			for i, path := range assignment.Paths {
+				if assignment.AntsPerPath[i] > 0 {
+					df.launches = append(df.launches, launchPath{path: path, remaining: assignment.AntsPerPath[i]})
+					paths = append(paths, fmt.Sprintf("%s (%d)", strings.Join(path, "-"), assignment.AntsPerPath[i]))
+				}
+			}
+			log = append(log, fmt.Sprintf("%d waiting ant(s) planned on %s", df.waiting, strings.Join(paths, ", ")))
+		}
+	}
+
+	for _, ant := range df.ants {
+		if len(ant.route) > 0 && ant.route[len(ant.route)-1] == df.end && df.routeIsOpen(ant) {
+			continue
+		}
+		// an ant inside a tunnel keeps the room it is heading to, even
+		// when there is no open route on from there
+		from, kept := ant.room, []string(nil)
+		if ant.transit > 0 {
+			from, kept = ant.route[0], ant.route[:1:1]
+		}
+		rest := df.fastestRoute(from)
+		ant.route = append(kept, rest...)
+		if rest == nil {
+			log = append(log, fmt.Sprintf("L%d at %s has no open route to the end room", ant.id, from))
+		} else {
+			log = append(log, fmt.Sprintf("L%d re-routed from %s via %s", ant.id, from, strings.Join(rest, "-")))
+		}
+	}
+	return log
+}
+
+// routeIsOpen reports whether every step left on the ant's route is open,
+// apart from the tunnel it may already be inside.
+func (df *dynamicFarm) routeIsOpen(ant *dynamicAnt) bool {
+	room, route := ant.room, ant.route
+	if ant.transit > 0 {
+		room, route = route[0], route[1:]
+	}
+	for _, next := range route {
+		if !df.isOpen(room, next) {
+			return false
+		}
+		room = next
+	}
+	return true
+}
+
+// fastestRoute returns the rooms after from of an open route to the end
+// room taking the fewest turns, not through the start room, or nil if there
+// is none.
+func (df *dynamicFarm) fastestRoute(from string) []string {
+	route, _ := graph.ShortestRoute(df.graph, from, df.end, graph.RouteOptions{SkipStart: true, Open: df.isOpen})
+	if route == nil {
+		return nil
+	}
+	return route[1:]
+}
+
+// step plays one turn: ants on the way move first, closest to the end
+// first, then one ant leaves the start room on every planned path. An ant
+// waits when its next room is full or its tunnel already used this turn.
+// An ant entering a tunnel of several turns leaves its room at once and
+// moves into the next room when it comes out.
+func (df *dynamicFarm) step() []structs.Move {
+	var moves []structs.Move
+	usedTunnels := make(map[[2]string]bool)
+	isFull := func(room string) bool {
+		return room != df.end && df.occupancy[room] >= df.capacity[room]
+	}
+	move := func(ant *dynamicAnt) bool {
+		next := ant.route[0]
+		switch {
+		case ant.transit > 1:
+			ant.transit--
+			return true
+		case ant.transit == 1:
+			if isFull(next) {
+				return false
+			}
+		default:
+			tunnel := structs.TunnelKey(ant.room, next)
+			turns := df.graph.TunnelTurns(ant.room, next)
+			if !df.isOpen(ant.room, next) || usedTunnels[tunnel] || (turns == 1 && isFull(next)) {
+				return false
+			}
+			usedTunnels[tunnel] = true
+			if ant.room != df.start {
+				df.occupancy[ant.room]--
+			}
+			if turns > 1 {
+				ant.transit = turns - 1
+				return true
+			}
+		}
+		ant.transit = 0
+		if next != df.end {
+			df.occupancy[next]++
+		}
+		moves = append(moves, structs.Move{Ant: ant.id, From: ant.room, To: next})
+		ant.room, ant.route = next, ant.route[1:]
+		return true
+	}
+
+	sort.SliceStable(df.ants, func(i, j int) bool {
+		if len(df.ants[i].route) != len(df.ants[j].route) {
+			return len(df.ants[i].route) < len(df.ants[j].route)
+		}
+		return df.ants[i].transit < df.ants[j].transit
+	})
+	for _, ant := range df.ants {
+		if len(ant.route) > 0 {
+			move(ant)
+		}
+	}
+	for i := range df.launches {
+		launch := &df.launches[i]
+		if launch.remaining == 0 {
+			continue
+		}
+		ant := &dynamicAnt{id: df.nextID, room: df.start, route: launch.path[1:]}
+		if move(ant) {
+			df.nextID++
+			df.waiting--
+			launch.remaining--
+			df.ants = append(df.ants, ant)
+		}
+	}
+
+	// ants in the end room are done
+	remaining := df.ants[:0]
+	for _, ant := range df.ants {
+		if ant.room != df.end {
+			remaining = append(remaining, ant)
+		}
+	}
+	df.ants = remaining
+
+	sort.Slice(moves, func(i, j int) bool { return moves[i].Ant < moves[j].Ant })
+	return moves
+}
+
+// turnState records where the ants are after a turn; ants inside a tunnel
+// are in no room.
+func (df *dynamicFarm) turnState() structs.TurnState {
+	state := structs.TurnState{
+		Rooms:   make(map[string][]int),
+		AtStart: df.waiting,
+		AtEnd:   df.nextID - 1 - len(df.ants),
+	}
+	for _, ant := range df.ants {
+		if ant.transit > 0 {
+			continue
+		}
+		state.Rooms[ant.room] = append(state.Rooms[ant.room], ant.id)
+	}
+	for _, ants := range state.Rooms {
+		sort.Ints(ants)
+	}
+	return state
+}
+
+// inTransit reports whether any ant is inside a tunnel.
+func (df *dynamicFarm) inTransit() bool {
+	for _, ant := range df.ants {
+		if ant.transit > 0 {
+			return true
+		}
+	}
+	return false
+}
+
+// grid lists the intermediate rooms and the tunnels holding ants, in name
+// order.
+func (df *dynamicFarm) grid() string {
+	byRoom := make(map[string][]string)
+	var rooms []string
+	for _, ant := range df.ants {
+		place := ant.room
+		if ant.transit > 0 {
+			place = ant.room + " ---> " + ant.route[0]
+		}
+		if _, ok := byRoom[place]; !ok {
+			rooms = append(rooms, place)
+		}
+		byRoom[place] = append(byRoom[place], fmt.Sprintf("L%d", ant.id))
+	}
+	sort.Strings(rooms)
+	var builder strings.Builder
+	for _, room := range rooms {
+		builder.WriteString(fmt.Sprintf("[ %s (%s) ]\n", room, strings.Join(byRoom[room], ", ")))
+	}
+	if len(rooms) == 0 {
+		builder.WriteString("(no ants between start and end)\n")
+	}
+	return builder.String()
+}
@@ -2,6 +2,7 @@ package simulation
 
 import (
 	"fmt"
+	"sort"
 	"strings"
 
 	"lem-in/structs"
@@ -19,6 +20,7 @@ func initSimulation(pathList [][]string, assignment structs.PathAssignment) []st
 		for j := range positions {
 			positions[j] = -1
 		}
+		transit := make([]int, antCountForPath)
 		antIDs := make([]int, antCountForPath)
 		for j := 0; j < antCountForPath; j++ {
 			antIDs[j] = antIDCounter
@@ -27,90 +29,166 @@ func initSimulation(pathList [][]string, assignment structs.PathAssignment) []st
 		simStates[i] = structs.PathSim{
 			Path:      path,
 			Positions: positions,
+			Transit:   transit,
 			AntIDs:    antIDs,
 		}
 	}
 	return simStates
 }
 
-// isRoomOccupied checks if any ant is at the given index.
-func isRoomOccupied(positions []int, roomIndex int) bool {
-	for _, pos := range positions {
-		if pos == roomIndex {
-			return true
-		}
+// roomCapacities maps every room of farmGraph to the number of ants it
+// holds at once.
+func roomCapacities(farmGraph *structs.Graph) map[string]int {
+	capacity := make(map[string]int, len(farmGraph.Rooms))
+	for name, room := range farmGraph.Rooms {
+		capacity[name] = room.MaxAnts()
 	}
-	return false
+	return capacity
 }
 
-// processTurn moves ants one step along each path.
-func processTurn(simStates []structs.PathSim) ([]string, string) {
-	var moveDescriptions []string
-	var gridBuilder strings.Builder
+// processTurn moves ants one step along each path. occupancy counts the ants
+// in every intermediate room and is updated as ants move; an ant waits while
+// its next room is full. An ant entering a tunnel of several turns leaves
+// its room at once and moves into the next room when it comes out. Every
+// tunnel may be entered by one ant per turn; a schedule that needs a tunnel
+// twice in the same turn is an error.
+func processTurn(farmGraph *structs.Graph, simStates []structs.PathSim, occupancy, capacity map[string]int) ([]structs.Move, error) {
+	var moves []structs.Move
+	usedTunnels := make(map[[2]string]int)
 
 	for idx := range simStates {
 		simState := &simStates[idx]
 		pathLength := len(simState.Path)
-		newPositions := make([]int, len(simState.Positions))
-		copy(newPositions, simState.Positions)
-
-		if pathLength == 2 {
-			// direct path: inject one ant
-			for j := range simState.Positions {
-				if simState.Positions[j] == -1 {
-					newPositions[j] = 1
-					moveDescriptions = append(moveDescriptions,
-						fmt.Sprintf("L%d-%s", simState.AntIDs[j], simState.Path[1]))
-					break
-				}
+		antCount := len(simState.Positions)
+
+		// ants ahead of the next one to leave the start move first; ants
+		// that already arrived are skipped
+		nextToLaunch := antCount - simState.Launched - 1
+		for j := antCount - simState.Arrived - 1; j >= nextToLaunch && j >= 0; j-- {
+			current := simState.Positions[j]
+			if simState.Transit[j] > 1 {
+				// still inside a tunnel
+				simState.Transit[j]--
+				continue
+			}
+			inTunnel := simState.Transit[j] == 1
+			nextIndex := current + 1
+			switch {
+			case inTunnel:
+				nextIndex = current
+			case current == -1:
+				nextIndex = 1
+			}
+			from, to := simState.Path[nextIndex-1], simState.Path[nextIndex]
+			turns := farmGraph.TunnelTurns(from, to)
+
+			isEnd := nextIndex == pathLength-1
+			if !isEnd && occupancy[to] >= capacity[to] && (inTunnel || turns == 1) {
+				continue
 			}
-		} else {
-			// longer path: move existing ants first
-			for j := len(simState.Positions) - 1; j >= 0; j-- {
-				if simState.Positions[j] == -1 {
-					if !isRoomOccupied(newPositions, 1) {
-						newPositions[j] = 1
-						moveDescriptions = append(moveDescriptions,
-							fmt.Sprintf("L%d-%s", simState.AntIDs[j], simState.Path[1]))
-					}
-				} else if simState.Positions[j] < pathLength-1 {
-					nextIndex := simState.Positions[j] + 1
-					if nextIndex == pathLength-1 || !isRoomOccupied(newPositions, nextIndex) {
-						newPositions[j] = nextIndex
-						moveDescriptions = append(moveDescriptions,
-							fmt.Sprintf("L%d-%s", simState.AntIDs[j], simState.Path[nextIndex]))
-					}
+			if !inTunnel {
+				tunnel := structs.TunnelKey(from, to)
+				if user, used := usedTunnels[tunnel]; used {
+					return nil, fmt.Errorf("tunnel %s-%s is needed by L%d and L%d in the same turn",
+						tunnel[0], tunnel[1], user, simState.AntIDs[j])
 				}
+				usedTunnels[tunnel] = simState.AntIDs[j]
+
+				if current == -1 {
+					simState.Launched++
+				} else {
+					occupancy[from]--
+				}
+				simState.Positions[j] = nextIndex
+				if turns > 1 {
+					simState.Transit[j] = turns - 1
+					continue
+				}
+			}
+			simState.Transit[j] = 0
+			if isEnd {
+				simState.Arrived++
+			} else {
+				occupancy[to]++
 			}
+			moves = append(moves, structs.Move{Ant: simState.AntIDs[j], From: from, To: to})
 		}
-
-		copy(simState.Positions, newPositions)
-		gridBuilder.WriteString(visualizer.GeneratePathGrid(*simState) + "\n")
 	}
 
-	return moveDescriptions, gridBuilder.String()
+	return moves, nil
 }
 
-// SimulateMultiPath runs the simulation until completion.
-func SimulateMultiPath(antTotal int, pathList [][]string, assignment structs.PathAssignment, headerInfo string) {
+// inTransit reports whether any ant is inside a tunnel.
+func inTransit(simStates []structs.PathSim) bool {
+	for _, simState := range simStates {
+		for _, transit := range simState.Transit {
+			if transit > 0 {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// Run simulates the ants turn by turn on the paths of farmGraph until every
+// ant has reached the end room and returns the number of turns. After each
+// turn onTurn, if not nil, gets the moves of the turn and the state of every
+// path.
+func Run(farmGraph *structs.Graph, pathList [][]string, assignment structs.PathAssignment,
+	onTurn func(moves []structs.Move, simStates []structs.PathSim)) (int, error) {
 	simStates := initSimulation(pathList, assignment)
-	var moveOutputs, gridOutputs []string
-	turnCount := 0
+	occupancy := make(map[string]int)
+	capacity := roomCapacities(farmGraph)
 
-	for {
-		moves, grid := processTurn(simStates)
-		if len(moves) == 0 {
-			break
+	for turn := 1; ; turn++ {
+		moves, err := processTurn(farmGraph, simStates, occupancy, capacity)
+		if err != nil {
+			return turn - 1, fmt.Errorf("turn %d: %v", turn, err)
+		}
+		if len(moves) == 0 && !inTransit(simStates) {
+			return turn - 1, nil
+		}
+		if onTurn != nil {
+			onTurn(moves, simStates)
 		}
-		moveOutputs = append(moveOutputs, strings.Join(moves, " "))
-		gridOutputs = append(gridOutputs, grid)
-		turnCount++
 	}
+}
 
-	err := visualizer.WriteSimulationOutput("simulation_output.txt", headerInfo, gridOutputs, turnCount)
-	if err != nil {
-		fmt.Println("Error writing simulation output:", err)
+// Simulate runs the simulation and keeps the moves and the path grids of
+// every turn.
+func Simulate(farmGraph *structs.Graph, pathList [][]string, assignment structs.PathAssignment) (structs.SimResult, error) {
+	var result structs.SimResult
+	_, err := Run(farmGraph, pathList, assignment, func(moves []structs.Move, simStates []structs.PathSim) {
+		var grid strings.Builder
+		for _, simState := range simStates {
+			grid.WriteString(visualizer.GeneratePathGrid(simState) + "\n")
+		}
+		result.Turns = append(result.Turns, moves)
+		result.Grids = append(result.Grids, grid.String())
+		result.States = append(result.States, turnState(simStates))
+	})
+	return result, err
+}
+
+// turnState records where the ants of every path are. Only the ants that
+// have left the start room and not yet arrived are looked at; ants inside a
+// tunnel are in no room.
+func turnState(simStates []structs.PathSim) structs.TurnState {
+	state := structs.TurnState{Rooms: make(map[string][]int)}
+	for _, simState := range simStates {
+		antCount := len(simState.Positions)
+		state.AtStart += antCount - simState.Launched
+		state.AtEnd += simState.Arrived
+		for j := antCount - simState.Launched; j < antCount-simState.Arrived; j++ {
+			if simState.Transit[j] > 0 {
+				continue
+			}
+			room := simState.Path[simState.Positions[j]]
+			state.Rooms[room] = append(state.Rooms[room], simState.AntIDs[j])
+		}
+	}
+	for _, ants := range state.Rooms {
+		sort.Ints(ants)
 	}
-	visualizer.PrintTerminalOutput(moveOutputs)
-	fmt.Println("2D grid visualization written to simulation_output.txt")
+	return state
 }
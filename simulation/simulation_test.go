@@ -0,0 +1,107 @@
+package simulation
+
+import (
+	"testing"
+
+	"lem-in/graph"
+	"lem-in/internal/benchfarm"
+	"lem-in/scheduling"
+	"lem-in/structs"
+)
+
+// TestProcessTurnCapacity sends one ant down each of two paths that meet in
+// room c: both fit in c when it holds two ants, and one waits when it holds
+// one.
+func TestProcessTurnCapacity(t *testing.T) {
+	paths := [][]string{{"s", "a", "c", "d", "e"}, {"s", "b", "c", "f", "e"}}
+	assignment := structs.PathAssignment{Paths: paths, AntsPerPath: []int{1, 1}}
+	g := &structs.Graph{}
+	for _, tc := range []struct {
+		capacity  int
+		wantMoves int
+	}{
+		{2, 2},
+		{1, 1},
+	} {
+		simStates := initSimulation(paths, assignment)
+		occupancy := make(map[string]int)
+		capacity := map[string]int{"a": 1, "b": 1, "c": tc.capacity, "d": 1, "f": 1}
+		if _, err := processTurn(g, simStates, occupancy, capacity); err != nil {
+			t.Fatal(err)
+		}
+		moves, err := processTurn(g, simStates, occupancy, capacity)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(moves) != tc.wantMoves || occupancy["c"] != tc.wantMoves {
+			t.Errorf("capacity %d: got moves %v and %d ants in c, want %d",
+				tc.capacity, moves, occupancy["c"], tc.wantMoves)
+		}
+	}
+}
+
+// TestProcessTurnTransit sends an ant through tunnel a-c, which takes two
+// turns, while another ant fills room c: the ant stays in the tunnel until
+// c is free.
+func TestProcessTurnTransit(t *testing.T) {
+	paths := [][]string{{"s", "a", "c", "e"}}
+	assignment := structs.PathAssignment{Paths: paths, AntsPerPath: []int{1}}
+	g := &structs.Graph{Weights: map[[2]string]int{structs.TunnelKey("a", "c"): 2}}
+	simStates := initSimulation(paths, assignment)
+	occupancy := make(map[string]int)
+	capacity := map[string]int{"a": 1, "c": 1}
+
+	turn := func() []structs.Move {
+		moves, err := processTurn(g, simStates, occupancy, capacity)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return moves
+	}
+	turn() // L1-a
+	if moves := turn(); len(moves) != 0 || occupancy["a"] != 0 {
+		t.Fatalf("entering the tunnel: got moves %v and %d ants in a", moves, occupancy["a"])
+	}
+	if state := turnState(simStates); len(state.Rooms) != 0 {
+		t.Errorf("an ant in the tunnel is in rooms %v", state.Rooms)
+	}
+
+	occupancy["c"] = 1
+	if moves := turn(); len(moves) != 0 || simStates[0].Transit[0] != 1 {
+		t.Fatalf("c full: got moves %v with %d turns left in the tunnel", moves, simStates[0].Transit[0])
+	}
+	occupancy["c"] = 0
+	moves := turn()
+	if len(moves) != 1 || moves[0] != (structs.Move{Ant: 1, From: "a", To: "c"}) {
+		t.Errorf("c free: got moves %v, want L1-c", moves)
+	}
+}
+
+// BenchmarkProcessTurn times single turns; when every ant has arrived the
+// simulation starts over outside the timer.
+func BenchmarkProcessTurn(b *testing.B) {
+	benchfarm.Run(b, "grid", func(b *testing.B, farm *structs.Farm, g *structs.Graph) {
+		paths, err := graph.GetOptimalPaths(g, farm.AntCount)
+		if err != nil {
+			b.Fatal(err)
+		}
+		assignment := scheduling.AssignAnts(g, farm.AntCount, paths)
+
+		simStates := initSimulation(paths, assignment)
+		occupancy, capacity := make(map[string]int), roomCapacities(g)
+		b.ReportAllocs()
+		b.ResetTimer()
+		for i := 0; i < b.N; i++ {
+			moves, err := processTurn(g, simStates, occupancy, capacity)
+			if err != nil {
+				b.Fatal(err)
+			}
+			if len(moves) == 0 {
+				b.StopTimer()
+				simStates = initSimulation(paths, assignment)
+				occupancy = make(map[string]int)
+				b.StartTimer()
+			}
+		}
+	})
+}
@@ -0,0 +1,122 @@
+package solver
+
+import (
+	"errors"
+	"fmt"
+
+	"lem-in/graph"
+	"lem-in/scheduling"
+	"lem-in/structs"
+	"lem-in/timeexpanded"
+)
+
+// ErrNoPath is returned when no path leads from the start room to the end
+// room.
+var ErrNoPath = errors.New("no path from start to end")
+
+// Plan is a solver's answer for a farm.
+type Plan struct {
+	// Candidates are the path sets the solver considered.
+	Candidates [][][]string
+	// Assignment holds the chosen paths and the ants sent along each.
+	Assignment structs.PathAssignment
+	// Schedule holds the moves of every turn for solvers that schedule the
+	// ants themselves; nil means the ants follow Assignment in the
+	// simulation.
+	Schedule *structs.SimResult
+}
+
+// Solver finds a plan moving antCount ants from start to end.
+type Solver interface {
+	Solve(farmGraph *structs.Graph, antCount int) (Plan, error)
+}
+
+// Func adapts a function to the Solver interface.
+type Func func(farmGraph *structs.Graph, antCount int) (Plan, error)
+
+// Solve calls f.
+func (f Func) Solve(farmGraph *structs.Graph, antCount int) (Plan, error) {
+	return f(farmGraph, antCount)
+}
+
+var (
+	solvers = make(map[string]Solver)
+	names   []string
+)
+
+// Register makes a solver available under name. It panics when the name is
+// taken.
+func Register(name string, s Solver) {
+	if _, taken := solvers[name]; taken {
+		panic(fmt.Sprintf("solver %q registered twice", name))
+	}
+	solvers[name] = s
+	names = append(names, name)
+}
+
+// Get returns the solver registered under name.
+func Get(name string) (Solver, error) {
+	s, ok := solvers[name]
+	if !ok {
+		return nil, fmt.Errorf("unknown solver %q", name)
+	}
+	return s, nil
+}
+
+// Names lists the registered solvers in registration order.
+func Names() []string {
+	return append([]string(nil), names...)
+}
+
+func init() {
+	Register("maxflow", Func(solveMaxFlow))
+	Register("enumerate", Func(solveEnumerate))
+	Register("timeexpanded", Func(solveTimeExpanded))
+	Register("shortest", Func(solveShortest))
+}
+
+// solveMaxFlow keeps the disjoint path set, among those found while the
+// flow grows, that moves the ants in the fewest turns.
+func solveMaxFlow(farmGraph *structs.Graph, antCount int) (Plan, error) {
+	candidates, err := graph.GetCandidatePathSets(farmGraph)
+	if err != nil {
+		return Plan{}, ErrNoPath
+	}
+	paths := graph.BestPathSet(farmGraph, candidates, antCount)
+	return Plan{Candidates: candidates, Assignment: scheduling.AssignAnts(farmGraph, antCount, paths)}, nil
+}
+
+// solveEnumerate lists every simple path and picks disjoint ones by how
+// crowded their rooms are. Farms with too many paths are an error.
+func solveEnumerate(farmGraph *structs.Graph, antCount int) (Plan, error) {
+	paths, err := graph.GetSeparateRoutes(farmGraph)
+	if errors.Is(err, graph.ErrTooManyRoutes) {
+		return Plan{}, err
+	}
+	if err != nil || len(paths) == 0 {
+		return Plan{}, ErrNoPath
+	}
+	return Plan{Candidates: [][][]string{paths}, Assignment: scheduling.AssignAnts(farmGraph, antCount, paths)}, nil
+}
+
+// solveTimeExpanded schedules the ants over the farm copied once per turn.
+func solveTimeExpanded(farmGraph *structs.Graph, antCount int) (Plan, error) {
+	if _, err := graph.GetShortestPath(farmGraph); err != nil {
+		return Plan{}, ErrNoPath
+	}
+	assignment, schedule, err := timeexpanded.Solve(farmGraph, antCount)
+	if err != nil {
+		return Plan{}, err
+	}
+	return Plan{Candidates: [][][]string{assignment.Paths}, Assignment: assignment, Schedule: &schedule}, nil
+}
+
+// solveShortest sends every ant along a single shortest path.
+func solveShortest(farmGraph *structs.Graph, antCount int) (Plan, error) {
+	path, err := graph.GetShortestPath(farmGraph)
+	if err != nil {
+		return Plan{}, ErrNoPath
+	}
+	paths := [][]string{path}
+	return Plan{Candidates: [][][]string{paths}, Assignment: scheduling.AssignAnts(farmGraph, antCount, paths)}, nil
+}
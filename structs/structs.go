@@ -1,35 +1,145 @@
 package structs
 
+import "fmt"
+
 // Room holds a room's data.
 type Room struct {
-	Name    string
-	X       int
-	Y       int
-	IsStart bool
-	IsEnd   bool
+	Name     string
+	X        int
+	Y        int
+	IsStart  bool
+	IsEnd    bool
+	Capacity int // ants the room holds at once, set by ##capacity; 0 means one
+	Line     int // input line the room was read from, 0 if unknown
+}
+
+// MaxAnts returns how many ants the room holds at once. The start and end
+// rooms hold any number; callers handle them separately.
+func (r *Room) MaxAnts() int {
+	if r.Capacity > 0 {
+		return r.Capacity
+	}
+	return 1
 }
 
 // Tunnel represents a connection between two rooms.
 type Tunnel struct {
-	RoomA string
-	RoomB string
+	RoomA  string
+	RoomB  string
+	Weight int // turns an ant takes to cross it, set by "a-b 3"; 0 means one
+}
+
+// Turns returns how many turns an ant takes to cross the tunnel.
+func (t Tunnel) Turns() int {
+	if t.Weight > 0 {
+		return t.Weight
+	}
+	return 1
+}
+
+// Farm is a parsed farm description.
+type Farm struct {
+	AntCount int
+	Rooms    []Room
+	Tunnels  []Tunnel
+	Start    string
+	End      string
+	Comments []string
+	Lines    []string // non-empty input lines, as read
 }
 
 // Graph stores rooms and adjacency.
 type Graph struct {
 	Rooms     map[string]*Room
 	Neighbors map[string][]string
+	Weights   map[[2]string]int // tunnels taking more than one turn, by TunnelKey
+}
+
+// TunnelKey names an undirected tunnel the same way in both directions.
+func TunnelKey(a, b string) [2]string {
+	if a > b {
+		a, b = b, a
+	}
+	return [2]string{a, b}
+}
+
+// TunnelTurns returns how many turns an ant takes to go from room a to its
+// neighbor b.
+func (g *Graph) TunnelTurns(a, b string) int {
+	if weight, ok := g.Weights[TunnelKey(a, b)]; ok {
+		return weight
+	}
+	return 1
+}
+
+// PathTurns returns how many turns one ant takes to walk path, the sum of
+// the turns of its tunnels.
+func (g *Graph) PathTurns(path []string) int {
+	turns := 0
+	for i := 1; i < len(path); i++ {
+		turns += g.TunnelTurns(path[i-1], path[i])
+	}
+	return turns
 }
 
 // PathAssignment maps paths to ant counts.
 type PathAssignment struct {
-	Paths       [][]string
-	AntsPerPath []int
+	Paths          [][]string
+	AntsPerPath    []int
+	PredictedTurns int
 }
 
-// PathSim tracks ants on a path.
+// PathSim tracks ants on a path. Ants leave the start from the last index
+// down, so Launched and Arrived count ants from the end of the slices. An
+// ant with a Transit above zero is still inside the tunnel leading to the
+// room at its position and arrives after that many more turns.
 type PathSim struct {
 	Path      []string
 	Positions []int
+	Transit   []int
 	AntIDs    []int
+	Launched  int
+	Arrived   int
+}
+
+// Move is one ant stepping into a room during a turn. After a tunnel that
+// takes several turns, the move comes on the turn the ant arrives.
+type Move struct {
+	Ant  int
+	From string
+	To   string
+}
+
+// String formats the move the way the subject prints it: Lx-room.
+func (m Move) String() string {
+	return fmt.Sprintf("L%d-%s", m.Ant, m.To)
+}
+
+// SimResult holds the moves, grid snapshots and ant positions of every
+// turn of a run, and the log of re-plans when the farm changed during the
+// run.
+type SimResult struct {
+	Turns   [][]Move
+	Grids   []string
+	States  []TurnState
+	Replans []string
+}
+
+// TurnState is where the ants are at the end of a turn. Ants inside a
+// tunnel are in none of its rooms.
+type TurnState struct {
+	Rooms   map[string][]int // ants in each intermediate room, in ant order
+	AtStart int              // ants still waiting in the start room
+	AtEnd   int              // ants that have reached the end room
+}
+
+// LowerBound is a lower bound on the turns any schedule needs: at most
+// CutSize ants can cross the minimum cut per turn and none arrives before
+// ShortestPath turns (the tunnels of a shortest path, counting the turns each
+// takes to cross), so N ants need ShortestPath + ceil(N/CutSize) - 1.
+type LowerBound struct {
+	ShortestPath int
+	CutSize      int
+	Bottleneck   []string // rooms and tunnels ("a-b") of a minimum cut
+	Turns        int
 }
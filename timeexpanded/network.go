@@ -0,0 +1,125 @@
+package timeexpanded
+
+// network is a flow network. Edges are collected while the network is
+// built and then laid out per node (compressed sparse rows), which keeps
+// the searches over large expanded networks cache friendly.
+type network struct {
+	nodes int
+	edges [][3]int // from, to, capacity, before finish
+
+	first    []int // edges of node n are first[n] .. first[n+1]-1
+	to       []int
+	rev      []int // index of the reverse edge
+	capacity []int
+	initial  []int
+
+	level []int
+	iter  []int
+}
+
+// addNode adds a node and returns its index.
+func (n *network) addNode() int {
+	n.nodes++
+	return n.nodes - 1
+}
+
+// addEdge adds an edge; its zero-capacity reverse edge is added by finish.
+func (n *network) addEdge(from, to, capacity int) {
+	n.edges = append(n.edges, [3]int{from, to, capacity})
+}
+
+// finish lays the edges out per node. No edges may be added afterwards.
+func (n *network) finish() {
+	n.first = make([]int, n.nodes+1)
+	for _, e := range n.edges {
+		n.first[e[0]+1]++
+		n.first[e[1]+1]++
+	}
+	for i := 1; i <= n.nodes; i++ {
+		n.first[i] += n.first[i-1]
+	}
+	size := 2 * len(n.edges)
+	n.to = make([]int, size)
+	n.rev = make([]int, size)
+	n.capacity = make([]int, size)
+	n.initial = make([]int, size)
+	next := append([]int(nil), n.first[:n.nodes]...)
+	for _, e := range n.edges {
+		forward, backward := next[e[0]], next[e[1]]
+		next[e[0]]++
+		next[e[1]]++
+		n.to[forward], n.rev[forward], n.capacity[forward], n.initial[forward] = e[1], backward, e[2], e[2]
+		n.to[backward], n.rev[backward] = e[0], forward
+	}
+	n.edges = nil
+}
+
+// flow returns the flow on edge e, negative on reverse edges.
+func (n *network) flow(e int) int {
+	return n.initial[e] - n.capacity[e]
+}
+
+// maxFlow pushes up to limit units of flow from source to sink with
+// Dinic's algorithm and returns the amount pushed.
+func (n *network) maxFlow(source, sink, limit int) int {
+	total := 0
+	n.level = make([]int, n.nodes)
+	n.iter = make([]int, n.nodes)
+	for total < limit && n.buildLevels(source, sink) {
+		copy(n.iter, n.first)
+		for total < limit {
+			pushed := n.push(source, sink, limit-total)
+			if pushed == 0 {
+				break
+			}
+			total += pushed
+		}
+	}
+	return total
+}
+
+// buildLevels labels nodes with their BFS distance from source over edges
+// with spare capacity and reports whether sink was reached.
+func (n *network) buildLevels(source, sink int) bool {
+	for i := range n.level {
+		n.level[i] = -1
+	}
+	n.level[source] = 0
+	queue := []int{source}
+	for len(queue) > 0 {
+		node := queue[0]
+		queue = queue[1:]
+		// nodes as deep as the sink cannot lead to it on a shortest path
+		if n.level[sink] >= 0 && n.level[node] >= n.level[sink] {
+			break
+		}
+		for e := n.first[node]; e < n.first[node+1]; e++ {
+			if n.capacity[e] > 0 && n.level[n.to[e]] < 0 {
+				n.level[n.to[e]] = n.level[node] + 1
+				queue = append(queue, n.to[e])
+			}
+		}
+	}
+	return n.level[sink] >= 0
+}
+
+// push sends up to amount units from node towards sink along edges that go
+// one level deeper.
+func (n *network) push(node, sink, amount int) int {
+	if node == sink {
+		return amount
+	}
+	for ; n.iter[node] < n.first[node+1]; n.iter[node]++ {
+		e := n.iter[node]
+		next := n.to[e]
+		if n.capacity[e] <= 0 || n.level[next] != n.level[node]+1 {
+			continue
+		}
+		if pushed := n.push(next, sink, min(amount, n.capacity[e])); pushed > 0 {
+			n.capacity[e] -= pushed
+			n.capacity[n.rev[e]] += pushed
+			return pushed
+		}
+	}
+	return 0
+}
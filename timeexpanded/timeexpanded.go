@@ -0,0 +1,334 @@
+package timeexpanded
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+
+	"lem-in/graph"
+	"lem-in/scheduling"
+	"lem-in/structs"
+	"lem-in/visualizer"
+)
+
+// maxNodes caps the size of the expanded network; larger farms should use
+// the path-based solvers.
+const maxNodes = 2000000
+
+// expansion is the farm replicated once per turn. Node in(r, t) holds the
+// ants in room r at the end of turn t and passes at most the room's
+// capacity of them (any number for the start room) to out(r, t). From there
+// an ant either waits in r or crosses a tunnel node, which lets one ant per
+// turn in, into the copy of a neighboring room as many turns later as the
+// tunnel takes to cross. The end room has a single node per turn that
+// drains into the sink.
+type expansion struct {
+	net       *network
+	rooms     []string
+	turns     int
+	source    int
+	sink      int
+	startRoom int
+	endRoom   int
+	nodeRoom  []int // room of every in node, -1 for other nodes
+	nodeTurn  []int
+}
+
+// Solve finds a schedule that moves antCount ants from start to end in the
+// fewest possible turns. Unlike the path-based solvers it lets ants share
+// rooms and tunnels on different turns and wait in rooms. It searches turn
+// counts between the lower bound and the turns of the max-flow paths.
+func Solve(farmGraph *structs.Graph, antCount int) (structs.PathAssignment, structs.SimResult, error) {
+	bound, err := graph.GetLowerBound(farmGraph, antCount)
+	if err != nil {
+		return structs.PathAssignment{}, structs.SimResult{}, err
+	}
+	paths, err := graph.GetOptimalPaths(farmGraph, antCount)
+	if err != nil {
+		return structs.PathAssignment{}, structs.SimResult{}, err
+	}
+	upper := scheduling.AssignAnts(farmGraph, antCount, paths).PredictedTurns
+
+	tunnels := 0
+	for _, neighbors := range farmGraph.Neighbors {
+		tunnels += len(neighbors)
+	}
+	if nodes := (upper + 1) * (2*len(farmGraph.Rooms) + tunnels); nodes > maxNodes {
+		return structs.PathAssignment{}, structs.SimResult{}, fmt.Errorf(
+			"farm too large for the time-expanded solver: %d rooms over %d turns", len(farmGraph.Rooms), upper)
+	}
+
+	// fewest turns that let every ant through
+	low, high := bound.Turns, upper
+	for low < high {
+		mid := (low + high) / 2
+		x := expand(farmGraph, antCount, mid)
+		if x.net.maxFlow(x.source, x.sink, antCount) >= antCount {
+			high = mid
+		} else {
+			low = mid + 1
+		}
+	}
+
+	x := expand(farmGraph, antCount, low)
+	if x.net.maxFlow(x.source, x.sink, antCount) < antCount {
+		return structs.PathAssignment{}, structs.SimResult{}, errors.New("no schedule found")
+	}
+	assignment, result := x.schedule(antCount)
+	return assignment, result, nil
+}
+
+// expand builds the network for a schedule of the given number of turns.
+func expand(farmGraph *structs.Graph, antCount, turns int) *expansion {
+	rooms := make([]string, 0, len(farmGraph.Rooms))
+	for name := range farmGraph.Rooms {
+		rooms = append(rooms, name)
+	}
+	sort.Strings(rooms)
+	roomIndex := make(map[string]int, len(rooms))
+	x := &expansion{net: &network{}, rooms: rooms, turns: turns}
+	for i, name := range rooms {
+		roomIndex[name] = i
+		if farmGraph.Rooms[name].IsStart {
+			x.startRoom = i
+		}
+		if farmGraph.Rooms[name].IsEnd {
+			x.endRoom = i
+		}
+	}
+
+	addNode := func(room, turn int) int {
+		node := x.net.addNode()
+		x.nodeRoom = append(x.nodeRoom, room)
+		x.nodeTurn = append(x.nodeTurn, turn)
+		return node
+	}
+
+	in := make([][]int, turns+1)
+	out := make([][]int, turns+1)
+	for t := 0; t <= turns; t++ {
+		in[t] = make([]int, len(rooms))
+		out[t] = make([]int, len(rooms))
+		for r := range rooms {
+			in[t][r] = addNode(r, t)
+			if r == x.endRoom {
+				out[t][r] = in[t][r]
+				continue
+			}
+			out[t][r] = addNode(-1, t)
+			capacity := farmGraph.Rooms[rooms[r]].MaxAnts()
+			if r == x.startRoom {
+				capacity = antCount
+			}
+			x.net.addEdge(in[t][r], out[t][r], capacity)
+		}
+	}
+	x.source = in[0][x.startRoom]
+	x.sink = addNode(-1, -1)
+	for t := 0; t <= turns; t++ {
+		x.net.addEdge(in[t][x.endRoom], x.sink, antCount)
+	}
+
+	for t := 0; t < turns; t++ {
+		// waiting in a room
+		for r := range rooms {
+			if r != x.endRoom {
+				x.net.addEdge(out[t][r], in[t+1][r], antCount)
+			}
+		}
+		// crossing a tunnel, one ant per turn in either direction; nobody
+		// leaves the end room or returns to the start room
+		for a, name := range rooms {
+			for _, neighbor := range farmGraph.Neighbors[name] {
+				b := roomIndex[neighbor]
+				if b < a {
+					continue
+				}
+				arrival := t + farmGraph.TunnelTurns(name, neighbor)
+				if arrival > turns {
+					continue
+				}
+				tunnelIn, tunnelOut := addNode(-1, t), addNode(-1, t)
+				x.net.addEdge(tunnelIn, tunnelOut, 1)
+				for _, dir := range [2][2]int{{a, b}, {b, a}} {
+					if dir[0] != x.endRoom && dir[1] != x.startRoom {
+						x.net.addEdge(out[t][dir[0]], tunnelIn, 1)
+						x.net.addEdge(tunnelOut, in[arrival][dir[1]], 1)
+					}
+				}
+			}
+		}
+	}
+	x.net.finish()
+	return x
+}
+
+// antRoute is the room of one ant at the end of every turn until it
+// arrives, or inTunnel while it crosses a tunnel.
+type antRoute []int
+
+// inTunnel marks the turns an ant spends inside a tunnel.
+const inTunnel = -1
+
+// departure is the turn the ant leaves the start room.
+func (r antRoute) departure() int {
+	for t := 1; t < len(r); t++ {
+		if r[t] != r[0] {
+			return t
+		}
+	}
+	return len(r)
+}
+
+// schedule decomposes the flow into one route per ant and turns them into
+// numbered moves. Ants are numbered in the order they leave the start room.
+func (x *expansion) schedule(antCount int) (structs.PathAssignment, structs.SimResult) {
+	used := make([]int, len(x.net.to))
+	routes := make([]antRoute, 0, antCount)
+	for len(routes) < antCount {
+		route := antRoute{x.startRoom}
+		node := x.source
+		for node != x.sink {
+			for e := x.net.first[node]; e < x.net.first[node+1]; e++ {
+				if x.net.flow(e)-used[e] > 0 {
+					used[e]++
+					node = x.net.to[e]
+					break
+				}
+			}
+			if node != x.sink && x.nodeRoom[node] >= 0 && x.nodeTurn[node] >= len(route) {
+				for len(route) < x.nodeTurn[node] {
+					route = append(route, inTunnel)
+				}
+				route = append(route, x.nodeRoom[node])
+			}
+		}
+		routes = append(routes, route)
+	}
+	sort.SliceStable(routes, func(i, j int) bool {
+		return routes[i].departure() < routes[j].departure()
+	})
+
+	// distinct room sequences become the paths of the assignment
+	pathOf := make([]int, len(routes))
+	pathIndex := make(map[string]int)
+	var paths [][]string
+	for i, route := range routes {
+		var path []string
+		for t, room := range route {
+			if room != inTunnel && (t == 0 || room != route[t-1]) {
+				path = append(path, x.rooms[room])
+			}
+		}
+		key := strings.Join(path, " ")
+		if _, ok := pathIndex[key]; !ok {
+			pathIndex[key] = len(paths)
+			paths = append(paths, path)
+		}
+		pathOf[i] = pathIndex[key]
+	}
+	order := make([]int, len(paths))
+	for i := range order {
+		order[i] = i
+	}
+	sort.Slice(order, func(i, j int) bool {
+		a, b := paths[order[i]], paths[order[j]]
+		if len(a) != len(b) {
+			return len(a) < len(b)
+		}
+		return strings.Join(a, " ") < strings.Join(b, " ")
+	})
+	rank := make([]int, len(paths))
+	assignment := structs.PathAssignment{
+		Paths:       make([][]string, len(paths)),
+		AntsPerPath: make([]int, len(paths)),
+	}
+	for i, p := range order {
+		rank[p] = i
+		assignment.Paths[i] = paths[p]
+	}
+
+	turnCount := 0
+	for _, route := range routes {
+		if len(route)-1 > turnCount {
+			turnCount = len(route) - 1
+		}
+	}
+	var result structs.SimResult
+	result.Turns = make([][]structs.Move, turnCount)
+	sims := make([]structs.PathSim, len(paths))
+	for i := range sims {
+		sims[i].Path = assignment.Paths[i]
+	}
+	for i, route := range routes {
+		ant := i + 1
+		path := rank[pathOf[i]]
+		assignment.AntsPerPath[path]++
+		sims[path].AntIDs = append(sims[path].AntIDs, ant)
+		from := route[0]
+		for t := 1; t < len(route); t++ {
+			if route[t] != inTunnel && route[t] != from {
+				result.Turns[t-1] = append(result.Turns[t-1], structs.Move{
+					Ant: ant, From: x.rooms[from], To: x.rooms[route[t]]})
+				from = route[t]
+			}
+		}
+	}
+	assignment.PredictedTurns = turnCount
+
+	// grids: the position of every ant along its path after each turn; an
+	// ant inside a tunnel is at the room it heads to, with the turns left
+	positions := make([]int, len(routes))
+	for t := 1; t <= turnCount; t++ {
+		for path := range sims {
+			sims[path].Positions = sims[path].Positions[:0]
+			sims[path].Transit = sims[path].Transit[:0]
+		}
+		for i, route := range routes {
+			transit := 0
+			if t < len(route) {
+				if route[t] != route[t-1] && route[t-1] != inTunnel {
+					positions[i]++
+				}
+				for u := t; u < len(route) && route[u] == inTunnel; u++ {
+					transit++
+				}
+			}
+			position := positions[i]
+			if position == 0 {
+				position = -1
+			}
+			path := rank[pathOf[i]]
+			sims[path].Positions = append(sims[path].Positions, position)
+			sims[path].Transit = append(sims[path].Transit, transit)
+		}
+		var grid strings.Builder
+		for _, sim := range sims {
+			grid.WriteString(visualizer.GeneratePathGrid(sim) + "\n")
+		}
+		result.Grids = append(result.Grids, grid.String())
+		result.States = append(result.States, x.turnState(routes, t))
+	}
+	return assignment, result
+}
+
+// turnState records the room of every ant at the end of turn t; ants inside
+// a tunnel are in none. Routes are in ant order, so the ants of every room
+// come out sorted.
+func (x *expansion) turnState(routes []antRoute, t int) structs.TurnState {
+	state := structs.TurnState{Rooms: make(map[string][]int)}
+	for i, route := range routes {
+		room := route[min(t, len(route)-1)]
+		switch room {
+		case inTunnel:
+		case x.startRoom:
+			state.AtStart++
+		case x.endRoom:
+			state.AtEnd++
+		default:
+			state.Rooms[x.rooms[room]] = append(state.Rooms[x.rooms[room]], i+1)
+		}
+	}
+	return state
+}
@@ -0,0 +1,260 @@
+package verify
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+
+	"lem-in/structs"
+)
+
+// Error describes the first illegal move found while replaying a solution.
+type Error struct {
+	Turn   int
+	Move   string
+	Reason string
+}
+
+func (e *Error) Error() string {
+	if e.Move == "" {
+		return fmt.Sprintf("turn %d: %s", e.Turn, e.Reason)
+	}
+	return fmt.Sprintf("turn %d: %s: %s", e.Turn, e.Move, e.Reason)
+}
+
+// ParseMoves reads a solution: one line of space-separated Lx-room moves per
+// turn. An echoed farm followed by a blank line (the subject output) and
+// "Turn N:" prefixes (the text output) are both accepted. Any other blank
+// line is a turn in which no ant arrives anywhere, as while ants cross
+// tunnels of several turns; trailing blank lines are ignored.
+func ParseMoves(r io.Reader) ([][]structs.Move, error) {
+	var lines []string
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		lines = append(lines, strings.TrimSpace(scanner.Text()))
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read moves: %v", err)
+	}
+
+	// skip the echoed farm and the blank line after it; without a farm,
+	// leading blank lines are turns in which no ant arrives
+	for i, line := range lines {
+		if line == "" {
+			continue
+		}
+		if !isTurnLine(line) {
+			for i < len(lines) && lines[i] != "" {
+				i++
+			}
+			lines = lines[min(i+1, len(lines)):]
+		}
+		break
+	}
+	for len(lines) > 0 && lines[len(lines)-1] == "" {
+		lines = lines[:len(lines)-1]
+	}
+
+	var turns [][]structs.Move
+	for _, line := range lines {
+		line = stripTurnPrefix(line)
+		var moves []structs.Move
+		for _, token := range strings.Fields(line) {
+			move, ok := parseMove(token)
+			if !ok {
+				return nil, &Error{Turn: len(turns) + 1, Move: token, Reason: "not a move of the form Lx-room"}
+			}
+			moves = append(moves, move)
+		}
+		turns = append(turns, moves)
+	}
+	return turns, nil
+}
+
+// isTurnLine reports whether line looks like a line of moves.
+func isTurnLine(line string) bool {
+	return strings.HasPrefix(line, "L") || strings.HasPrefix(line, "Turn ")
+}
+
+// stripTurnPrefix removes a leading "Turn N:" from line.
+func stripTurnPrefix(line string) string {
+	if !strings.HasPrefix(line, "Turn ") {
+		return line
+	}
+	if colon := strings.Index(line, ":"); colon >= 0 {
+		return strings.TrimSpace(line[colon+1:])
+	}
+	return line
+}
+
+// parseMove parses one Lx-room token.
+func parseMove(token string) (structs.Move, bool) {
+	if !strings.HasPrefix(token, "L") {
+		return structs.Move{}, false
+	}
+	dash := strings.Index(token, "-")
+	if dash < 0 || dash == len(token)-1 {
+		return structs.Move{}, false
+	}
+	ant, err := strconv.Atoi(token[1:dash])
+	if err != nil {
+		return structs.Move{}, false
+	}
+	return structs.Move{Ant: ant, To: token[dash+1:]}, true
+}
+
+// Verify replays turns against farmGraph with antCount ants waiting in the
+// start room, and returns the number of turns once every ant has reached
+// the end room. It checks the rules of the subject: moves follow tunnels,
+// an ant moves at most once per turn, a tunnel is used at most once per
+// turn, and an intermediate room holds no more ants than its capacity (one
+// unless set by ##capacity) at the end of a turn. A move through a tunnel
+// of several turns is listed on the turn the ant arrives: the ant entered
+// the tunnel, and left its room, that many turns earlier, and that is the
+// turn the tunnel counts as used.
+func Verify(farmGraph *structs.Graph, antCount int, turns [][]structs.Move) (int, error) {
+	var startRoom, endRoom string
+	for name, room := range farmGraph.Rooms {
+		if room.IsStart {
+			startRoom = name
+		}
+		if room.IsEnd {
+			endRoom = name
+		}
+	}
+	if startRoom == "" || endRoom == "" {
+		return 0, fmt.Errorf("farm has no start or end room")
+	}
+
+	positions := make([]string, antCount+1)
+	arrivals := make([]int, antCount+1) // turn each ant reached its position
+	for ant := 1; ant <= antCount; ant++ {
+		positions[ant] = startRoom
+	}
+
+	// rooms left and entered on every turn, and the ants in each
+	// intermediate room once a turn is checked
+	left := make(map[int][]string)
+	entered := make(map[int][]string)
+	occupancy := make(map[string]int)
+	isIntermediate := func(room string) bool {
+		return room != startRoom && room != endRoom
+	}
+	// a turn's occupancy is final once no later move can have left a room
+	// on it, which is the longest tunnel minus one turns later
+	longest := 1
+	for _, weight := range farmGraph.Weights {
+		longest = max(longest, weight)
+	}
+	checked := 0
+	checkUpTo := func(last int) error {
+		for ; checked < last; checked++ {
+			turn := checked + 1
+			for _, room := range left[turn] {
+				occupancy[room]--
+			}
+			for _, room := range entered[turn] {
+				occupancy[room]++
+			}
+			for _, room := range entered[turn] {
+				if capacity := farmGraph.Rooms[room].MaxAnts(); occupancy[room] > capacity {
+					return &Error{Turn: turn, Reason: fmt.Sprintf(
+						"%d ants are in room %s, which holds %d", occupancy[room], room, capacity)}
+				}
+			}
+		}
+		return nil
+	}
+
+	usedTunnels := make(map[[2]string]map[int]bool) // tunnel -> turns it was entered
+	for i, moves := range turns {
+		turn := i + 1
+		movedAnts := make(map[int]bool)
+		for _, move := range moves {
+			fail := func(reason string, args ...interface{}) (int, error) {
+				return 0, &Error{Turn: turn, Move: move.String(), Reason: fmt.Sprintf(reason, args...)}
+			}
+			if move.Ant < 1 || move.Ant > antCount {
+				return fail("there is no ant %d", move.Ant)
+			}
+			if movedAnts[move.Ant] {
+				return fail("ant %d moves twice in one turn", move.Ant)
+			}
+			movedAnts[move.Ant] = true
+
+			from := positions[move.Ant]
+			if from == endRoom {
+				return fail("ant %d has already reached the end room", move.Ant)
+			}
+			if _, ok := farmGraph.Rooms[move.To]; !ok {
+				return fail("room %s does not exist", move.To)
+			}
+			if !isNeighbor(farmGraph, from, move.To) {
+				return fail("room %s is not connected to %s", move.To, from)
+			}
+			tunnel := structs.TunnelKey(from, move.To)
+			tunnelName := tunnel[0] + "-" + tunnel[1]
+			tunnelTurns := farmGraph.TunnelTurns(from, move.To)
+			entry := turn - tunnelTurns + 1
+			if entry <= arrivals[move.Ant] {
+				return fail("tunnel %s takes %d turns: ant %d cannot arrive before turn %d",
+					tunnelName, tunnelTurns, move.Ant, arrivals[move.Ant]+tunnelTurns)
+			}
+			if usedTunnels[tunnel] == nil {
+				usedTunnels[tunnel] = make(map[int]bool)
+			}
+			if usedTunnels[tunnel][entry] {
+				if tunnelTurns == 1 {
+					return fail("tunnel %s is used twice in one turn", tunnelName)
+				}
+				return fail("tunnel %s is entered twice on turn %d", tunnelName, entry)
+			}
+			usedTunnels[tunnel][entry] = true
+
+			if isIntermediate(from) {
+				left[entry] = append(left[entry], from)
+			}
+			if isIntermediate(move.To) {
+				entered[turn] = append(entered[turn], move.To)
+			}
+			positions[move.Ant], arrivals[move.Ant] = move.To, turn
+		}
+
+		// occupancy is checked once every ant that may have left a room on
+		// the turn has arrived
+		if err := checkUpTo(turn - longest + 1); err != nil {
+			return 0, err
+		}
+	}
+	if err := checkUpTo(len(turns)); err != nil {
+		return 0, err
+	}
+
+	var missing []string
+	missingCount := 0
+	for ant := 1; ant <= antCount; ant++ {
+		if positions[ant] != endRoom {
+			missing = append(missing, "L"+strconv.Itoa(ant))
+			missingCount++
+		}
+	}
+	if len(missing) > 10 {
+		missing = append(missing[:10], "...")
+	}
+	if len(missing) > 0 {
+		return 0, &Error{Turn: len(turns), Reason: fmt.Sprintf(
+			"%d ant(s) never reach the end room: %s", missingCount, strings.Join(missing, " "))}
+	}
+	return len(turns), nil
+}
+
+func isNeighbor(farmGraph *structs.Graph, from, to string) bool {
+	for _, next := range farmGraph.Neighbors[from] {
+		if next == to {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,152 @@
+package verify_test
+
+import (
+	"errors"
+	"strings"
+	"testing"
+
+	"lem-in/graph"
+	"lem-in/parser"
+	"lem-in/structs"
+	"lem-in/verify"
+)
+
+// testFarm has two routes from start to end that meet in room c.
+const testFarm = `2
+##start
+s 0 0
+a 1 0
+b 1 1
+c 2 0
+##end
+e 3 0
+s-a
+s-b
+a-c
+b-c
+c-e
+`
+
+// capacityFarm lets three ants reach room c, which holds two of them.
+const capacityFarm = `3
+##start
+s 0 0
+a 1 0
+b 1 1
+##capacity 2
+c 2 0
+##end
+e 3 0
+s-a
+s-b
+s-c
+a-c
+b-c
+c-e
+`
+
+// loadFarm parses a farm and builds its graph.
+func loadFarm(t *testing.T, text string) (*structs.Graph, int) {
+	t.Helper()
+	farm, err := parser.Parse(strings.NewReader(text))
+	if err != nil {
+		t.Fatal(err)
+	}
+	g, err := graph.BuildGraph(farm.Rooms, farm.Tunnels)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return g, farm.AntCount
+}
+
+// verifyMoves parses moves and checks them on the farm g.
+func verifyMoves(t *testing.T, g *structs.Graph, antCount int, moves string) (int, error) {
+	t.Helper()
+	turns, err := verify.ParseMoves(strings.NewReader(moves))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return verify.Verify(g, antCount, turns)
+}
+
+// checkRejected fails unless err is a *verify.Error for the given turn and
+// reason.
+func checkRejected(t *testing.T, err error, wantTurn int, wantReason string) {
+	t.Helper()
+	var verifyErr *verify.Error
+	if !errors.As(err, &verifyErr) {
+		t.Fatalf("got error %v, want a *verify.Error", err)
+	}
+	if verifyErr.Turn != wantTurn || verifyErr.Reason != wantReason {
+		t.Errorf("got turn %d %q, want turn %d %q",
+			verifyErr.Turn, verifyErr.Reason, wantTurn, wantReason)
+	}
+}
+
+func TestVerifyRejects(t *testing.T) {
+	g, antCount := loadFarm(t, testFarm)
+
+	tests := []struct {
+		name       string
+		moves      string
+		wantTurn   int
+		wantReason string
+	}{
+		{"unconnected room", "L1-c", 1, "room c is not connected to s"},
+		{"unknown room", "L1-x", 1, "room x does not exist"},
+		{"unknown ant", "L3-a", 1, "there is no ant 3"},
+		{"ant moves twice", "L1-a L1-c", 1, "ant 1 moves twice in one turn"},
+		{"tunnel used twice", "L1-a L2-a", 1, "tunnel a-s is used twice in one turn"},
+		{"move after the end", "L1-a\nL1-c\nL1-e\nL1-c", 4, "ant 1 has already reached the end room"},
+		{"room over capacity", "L1-a L2-b\nL1-c L2-c", 2, "2 ants are in room c, which holds 1"},
+		{"ants never arrive", "L1-a\nL1-c\nL1-e", 3, "1 ant(s) never reach the end room: L2"},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			_, err := verifyMoves(t, g, antCount, tc.moves)
+			checkRejected(t, err, tc.wantTurn, tc.wantReason)
+		})
+	}
+}
+
+func TestVerifyCapacity(t *testing.T) {
+	g, antCount := loadFarm(t, capacityFarm)
+
+	turns, err := verifyMoves(t, g, antCount, "L1-a L2-b L3-c\nL1-c L2-c L3-e\nL1-e\nL2-e")
+	if err != nil {
+		t.Fatalf("two ants in room c: %v", err)
+	}
+	if turns != 4 {
+		t.Errorf("got %d turns, want 4", turns)
+	}
+
+	_, err = verifyMoves(t, g, antCount, "L1-a L2-b L3-c\nL1-c L2-c")
+	checkRejected(t, err, 2, "3 ants are in room c, which holds 2")
+}
+
+// weightedFarm joins start and end with a tunnel that takes three turns.
+const weightedFarm = `2
+##start
+s 0 0
+##end
+e 3 0
+s-e 3
+`
+
+func TestVerifyWeighted(t *testing.T) {
+	g, antCount := loadFarm(t, weightedFarm)
+
+	// the blank lines are the turns the ants spend inside the tunnel
+	turns, err := verifyMoves(t, g, antCount, "\n\nL1-e\nL2-e")
+	if err != nil {
+		t.Fatalf("ants one turn apart: %v", err)
+	}
+	if turns != 4 {
+		t.Errorf("got %d turns, want 4", turns)
+	}
+
+	_, err = verifyMoves(t, g, antCount, "L1-e")
+	checkRejected(t, err, 1, "tunnel e-s takes 3 turns: ant 1 cannot arrive before turn 3")
+	_, err = verifyMoves(t, g, antCount, "\n\nL1-e L2-e")
+	checkRejected(t, err, 3, "tunnel e-s is entered twice on turn 1")
+}
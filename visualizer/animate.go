@@ -0,0 +1,315 @@
+package visualizer
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/signal"
+	"strconv"
+	"strings"
+	"syscall"
+	"time"
+
+	"lem-in/structs"
+)
+
+const (
+	ansiClear   = "\x1b[H\x1b[2J"
+	ansiHide    = "\x1b[?25l"
+	ansiShow    = "\x1b[?25h"
+	ansiReset   = "\x1b[0m"
+	ansiTunnel  = "\x1b[2m"
+	ansiRoom    = "\x1b[36m"
+	ansiEndRoom = "\x1b[1;35m"
+	ansiAnt     = "\x1b[1;33m"
+)
+
+// animation speeds, fastest first
+var frameDelays = []time.Duration{
+	50 * time.Millisecond, 100 * time.Millisecond, 250 * time.Millisecond,
+	500 * time.Millisecond, time.Second, 2 * time.Second,
+}
+
+// ErrInterrupted is returned by Animate when an interrupt or termination
+// signal stops the animation.
+var ErrInterrupted = errors.New("animation interrupted")
+
+// canvasCell is one character of the drawing with its color.
+type canvasCell struct {
+	ch    rune
+	color string
+}
+
+// Animate plays a simulation on the terminal, drawing every room at its
+// coordinates with the ants the simulation put there on each turn. Commands
+// are read one line at a time: Enter steps one turn forward, b steps back,
+// p plays or pauses, + and - change the speed and q quits. When stdin is not
+// a terminal, or once it is exhausted, the animation plays through to the
+// end and returns. An interrupt or termination signal stops it with
+// ErrInterrupted, after the cursor is shown again.
+func Animate(farm *structs.Farm, result structs.SimResult) error {
+	frames := append([]structs.TurnState{{AtStart: farm.AntCount}}, result.States...)
+	turns := result.Turns
+	width, height := terminalSize()
+
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, os.Interrupt, syscall.SIGTERM)
+	defer signal.Stop(signals)
+
+	interactive := isTerminal(os.Stdin)
+	commands := make(chan string)
+	if interactive {
+		go readCommands(os.Stdin, commands)
+	}
+
+	out := bufio.NewWriter(os.Stdout)
+	fmt.Fprint(out, ansiHide)
+	defer func() {
+		fmt.Fprint(out, ansiShow)
+		out.Flush()
+	}()
+
+	frame, speed, playing := 0, 3, true
+	if !interactive {
+		speed = 0
+	}
+	for {
+		fmt.Fprint(out, ansiClear)
+		fmt.Fprint(out, drawFrame(farm, frames[frame], width, height-4))
+		state := "paused"
+		if playing {
+			state = "playing"
+		}
+		fmt.Fprintf(out, "Turn %d/%d  [%s]  speed %v\n", frame, len(turns), state, frameDelays[speed])
+		if frame > 0 {
+			fmt.Fprintln(out, truncate(FormatMoves(turns[frame-1]), width))
+		} else {
+			fmt.Fprintln(out)
+		}
+		fmt.Fprintln(out, "Enter step  b back  p play/pause  +/- speed  q quit")
+		out.Flush()
+
+		if !interactive && frame == len(turns) {
+			return nil
+		}
+
+		var tick <-chan time.Time
+		if playing {
+			tick = time.After(frameDelays[speed])
+		}
+		select {
+		case <-signals:
+			fmt.Fprintln(out)
+			return ErrInterrupted
+		case <-tick:
+			if frame < len(turns) {
+				frame++
+			} else if interactive {
+				playing = false
+			}
+		case command, ok := <-commands:
+			if !ok {
+				// out of input: play the rest through
+				commands, interactive, playing = nil, false, true
+				continue
+			}
+			switch command {
+			case "q":
+				return nil
+			case "p":
+				playing = !playing
+				if playing && frame == len(turns) {
+					frame = 0
+				}
+			case "", "n":
+				playing = false
+				if frame < len(turns) {
+					frame++
+				}
+			case "b":
+				playing = false
+				if frame > 0 {
+					frame--
+				}
+			case "+":
+				if speed > 0 {
+					speed--
+				}
+			case "-":
+				if speed < len(frameDelays)-1 {
+					speed++
+				}
+			}
+		}
+	}
+}
+
+// drawFrame draws tunnels and rooms on a width x height canvas, with the
+// ants of state in their rooms.
+func drawFrame(farm *structs.Farm, state structs.TurnState, width, height int) string {
+	if width < 20 {
+		width = 20
+	}
+	if height < 5 {
+		height = 5
+	}
+	canvas := make([][]canvasCell, height)
+	for row := range canvas {
+		canvas[row] = make([]canvasCell, width)
+		for col := range canvas[row] {
+			canvas[row][col] = canvasCell{ch: ' '}
+		}
+	}
+
+	// leave room on the right for labels
+	place := layoutRooms(farm.Rooms, width-12, height)
+	for _, tunnel := range farm.Tunnels {
+		a, b := place[tunnel.RoomA], place[tunnel.RoomB]
+		drawLine(canvas, a[0], a[1], b[0], b[1])
+	}
+
+	for _, room := range farm.Rooms {
+		pos := place[room.Name]
+		label, color := room.Name, ansiRoom
+		if room.IsStart || room.IsEnd {
+			color = ansiEndRoom
+		}
+		count := len(state.Rooms[room.Name])
+		switch {
+		case room.IsStart:
+			count = state.AtStart
+		case room.IsEnd:
+			count = state.AtEnd
+		}
+		if count > 0 {
+			color = ansiAnt
+			if room.IsStart || room.IsEnd {
+				label += "(" + strconv.Itoa(count) + ")"
+			} else {
+				label += ":L" + strconv.Itoa(state.Rooms[room.Name][0])
+			}
+		}
+		for i, ch := range "[" + label + "]" {
+			col := pos[1] + i
+			if col >= width {
+				break
+			}
+			canvas[pos[0]][col] = canvasCell{ch: ch, color: color}
+		}
+	}
+
+	var builder strings.Builder
+	for _, row := range canvas {
+		current := ""
+		for _, cell := range row {
+			if cell.color != current {
+				builder.WriteString(ansiReset + cell.color)
+				current = cell.color
+			}
+			builder.WriteRune(cell.ch)
+		}
+		builder.WriteString(ansiReset + "\n")
+	}
+	return builder.String()
+}
+
+// layoutRooms maps room coordinates onto canvas rows and columns.
+func layoutRooms(rooms []structs.Room, width, height int) map[string][2]int {
+	minX, maxX, minY, maxY := 0, 0, 0, 0
+	for i, room := range rooms {
+		if i == 0 || room.X < minX {
+			minX = room.X
+		}
+		if i == 0 || room.X > maxX {
+			maxX = room.X
+		}
+		if i == 0 || room.Y < minY {
+			minY = room.Y
+		}
+		if i == 0 || room.Y > maxY {
+			maxY = room.Y
+		}
+	}
+	scale := func(value, low, high, size int) int {
+		if high == low || size <= 1 {
+			return 0
+		}
+		return (value - low) * (size - 1) / (high - low)
+	}
+
+	place := make(map[string][2]int, len(rooms))
+	for _, room := range rooms {
+		place[room.Name] = [2]int{scale(room.Y, minY, maxY, height), scale(room.X, minX, maxX, width)}
+	}
+	return place
+}
+
+// drawLine draws a dotted tunnel between two canvas cells (Bresenham).
+func drawLine(canvas [][]canvasCell, row0, col0, row1, col1 int) {
+	abs := func(v int) int {
+		if v < 0 {
+			return -v
+		}
+		return v
+	}
+	dCol, dRow := abs(col1-col0), -abs(row1-row0)
+	stepCol, stepRow := 1, 1
+	if col0 > col1 {
+		stepCol = -1
+	}
+	if row0 > row1 {
+		stepRow = -1
+	}
+	errTerm := dCol + dRow
+	for {
+		canvas[row0][col0] = canvasCell{ch: '.', color: ansiTunnel}
+		if row0 == row1 && col0 == col1 {
+			return
+		}
+		e2 := 2 * errTerm
+		if e2 >= dRow {
+			errTerm += dRow
+			col0 += stepCol
+		}
+		if e2 <= dCol {
+			errTerm += dCol
+			row0 += stepRow
+		}
+	}
+}
+
+// readCommands sends every line read from r, trimmed, as one command.
+func readCommands(r io.Reader, commands chan<- string) {
+	defer close(commands)
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		commands <- strings.TrimSpace(scanner.Text())
+	}
+}
+
+// isTerminal reports whether f is a terminal rather than a file or a pipe.
+func isTerminal(f *os.File) bool {
+	info, err := f.Stat()
+	return err == nil && info.Mode()&os.ModeCharDevice != 0
+}
+
+// terminalSize returns the terminal width and height from the COLUMNS and
+// LINES environment variables, 80x24 if they are not set.
+func terminalSize() (int, int) {
+	size := func(name string, fallback int) int {
+		if value, err := strconv.Atoi(os.Getenv(name)); err == nil && value > 0 {
+			return value
+		}
+		return fallback
+	}
+	return size("COLUMNS", 80), size("LINES", 24)
+}
+
+func truncate(text string, width int) string {
+	if len(text) > width {
+		return text[:width]
+	}
+	return text
+}
@@ -0,0 +1,211 @@
+package visualizer
+
+import (
+	"encoding/json"
+	"fmt"
+	"html"
+	"io"
+	"strings"
+
+	"lem-in/structs"
+)
+
+// replayData is the data the replay page animates, embedded as JSON.
+type replayData struct {
+	Turns int                   `json:"turns"`
+	Start string                `json:"start"`
+	End   string                `json:"end"`
+	Rooms map[string][2]float64 `json:"rooms"`
+	// Ants[i] lists the moves of ant i+1 as [turn, room] pairs
+	Ants [][][2]interface{} `json:"ants"`
+}
+
+// WriteHTML writes a self-contained HTML page that replays the simulation:
+// the SVG farm with every ant moving along its path, a timeline scrubber,
+// playback controls and per-ant highlighting. It uses no external assets.
+func WriteHTML(w io.Writer, farm *structs.Farm, assignment structs.PathAssignment, turns [][]structs.Move) error {
+	layout := newSVGLayout(farm.Rooms)
+
+	data := replayData{
+		Turns: len(turns),
+		Start: farm.Start,
+		End:   farm.End,
+		Rooms: layout.points,
+		Ants:  make([][][2]interface{}, farm.AntCount),
+	}
+	for i := range data.Ants {
+		data.Ants[i] = [][2]interface{}{}
+	}
+	for i, moves := range turns {
+		for _, move := range moves {
+			if move.Ant >= 1 && move.Ant <= farm.AntCount {
+				data.Ants[move.Ant-1] = append(data.Ants[move.Ant-1], [2]interface{}{i + 1, move.To})
+			}
+		}
+	}
+	dataJSON, err := json.Marshal(data)
+	if err != nil {
+		return err
+	}
+
+	var svg strings.Builder
+	fmt.Fprintf(&svg, `<svg id="farm" xmlns="http://www.w3.org/2000/svg" viewBox="0 0 %.0f %.0f" font-family="sans-serif" font-size="12">`+"\n",
+		layout.width, layout.height)
+	writeSVGFarm(&svg, farm, assignment, layout)
+	svg.WriteString(`<polyline id="route" fill="none" stroke="#000000" stroke-width="2" stroke-dasharray="6 4"/>` + "\n")
+	svg.WriteString(`<g id="ants"></g>` + "\n")
+	svg.WriteString("</svg>\n")
+
+	page := strings.NewReplacer(
+		"{{TITLE}}", html.EscapeString(fmt.Sprintf("lem-in: %d ants, %d turns", farm.AntCount, len(turns))),
+		"{{SVG}}", svg.String(),
+		"{{DATA}}", string(dataJSON),
+	).Replace(replayTemplate)
+	_, err = io.WriteString(w, page)
+	return err
+}
+
+const replayTemplate = `<!DOCTYPE html>
+<html>
+<head>
+<meta charset="utf-8">
+<title>{{TITLE}}</title>
+<style>
+body { font-family: sans-serif; margin: 16px; }
+#farm { max-width: 100%; max-height: 75vh; border: 1px solid #ddd; }
+#controls { margin: 8px 0; display: flex; gap: 8px; align-items: center; flex-wrap: wrap; }
+#scrubber { flex: 1; min-width: 200px; }
+.ant { fill: #ffd700; stroke: #333; stroke-width: 1; cursor: pointer; }
+.ant.dim { opacity: 0.25; }
+.ant.selected { fill: #000; r: 9; }
+#moves { font-family: monospace; white-space: pre-wrap; }
+</style>
+</head>
+<body>
+<h3>{{TITLE}}</h3>
+{{SVG}}
+<div id="controls">
+<button id="play">Pause</button>
+<button id="back">&#9664;</button>
+<button id="forward">&#9654;</button>
+<input id="scrubber" type="range" min="0" step="0.01" value="0">
+<span id="turn"></span>
+<label>Speed <select id="speed">
+<option value="0.25">0.25x</option><option value="0.5">0.5x</option>
+<option value="1" selected>1x</option><option value="2">2x</option><option value="4">4x</option>
+</select></label>
+<label>Ant <select id="antPick"><option value="0">all</option></select></label>
+</div>
+<div id="moves"></div>
+<script>
+(function () {
+  var data = {{DATA}};
+  var svgNS = "http://www.w3.org/2000/svg";
+  var antLayer = document.getElementById("ants");
+  var route = document.getElementById("route");
+  var scrubber = document.getElementById("scrubber");
+  var playButton = document.getElementById("play");
+  var turnLabel = document.getElementById("turn");
+  var movesBox = document.getElementById("moves");
+  var antPick = document.getElementById("antPick");
+  var speedPick = document.getElementById("speed");
+
+  var time = 0, playing = true, selected = 0, last = null;
+  scrubber.max = data.turns;
+
+  // room of an ant after a whole turn
+  function roomAt(ant, turn) {
+    var room = data.start, moves = data.ants[ant];
+    for (var i = 0; i < moves.length && moves[i][0] <= turn; i++) {
+      room = moves[i][1];
+    }
+    return room;
+  }
+
+  var circles = data.ants.map(function (moves, i) {
+    var c = document.createElementNS(svgNS, "circle");
+    c.setAttribute("r", 6);
+    c.setAttribute("class", "ant");
+    var title = document.createElementNS(svgNS, "title");
+    title.textContent = "L" + (i + 1);
+    c.appendChild(title);
+    c.addEventListener("click", function () { select(i + 1); });
+    antLayer.appendChild(c);
+    var option = document.createElement("option");
+    option.value = i + 1;
+    option.textContent = "L" + (i + 1);
+    antPick.appendChild(option);
+    return c;
+  });
+
+  function select(ant) {
+    selected = ant;
+    antPick.value = ant;
+    if (ant === 0) {
+      route.setAttribute("points", "");
+    } else {
+      var points = [data.rooms[data.start]].concat(data.ants[ant - 1].map(function (m) { return data.rooms[m[1]]; }));
+      route.setAttribute("points", points.map(function (p) { return p[0] + "," + p[1]; }).join(" "));
+    }
+    draw();
+  }
+
+  function draw() {
+    var whole = Math.floor(time), frac = time - whole;
+    circles.forEach(function (c, i) {
+      var from = data.rooms[roomAt(i, whole)], to = data.rooms[roomAt(i, whole + 1)];
+      if (whole >= data.turns) { to = from; }
+      c.setAttribute("cx", from[0] + (to[0] - from[0]) * frac);
+      c.setAttribute("cy", from[1] + (to[1] - from[1]) * frac);
+      var cls = "ant";
+      if (selected !== 0) { cls += (selected === i + 1) ? " selected" : " dim"; }
+      c.setAttribute("class", cls);
+    });
+    scrubber.value = time;
+    var shown = Math.min(data.turns, Math.ceil(time));
+    turnLabel.textContent = "Turn " + shown + " / " + data.turns;
+    var lines = [];
+    data.ants.forEach(function (moves, i) {
+      moves.forEach(function (m) { if (m[0] === shown) { lines.push("L" + (i + 1) + "-" + m[1]); } });
+    });
+    movesBox.textContent = lines.join(" ");
+  }
+
+  function setPlaying(on) {
+    playing = on;
+    playButton.textContent = on ? "Pause" : "Play";
+    last = null;
+  }
+
+  function tick(now) {
+    if (playing && last !== null) {
+      time += (now - last) / 1000 * parseFloat(speedPick.value);
+      if (time >= data.turns) { time = data.turns; setPlaying(false); }
+      draw();
+    }
+    last = now;
+    requestAnimationFrame(tick);
+  }
+
+  playButton.addEventListener("click", function () {
+    if (!playing && time >= data.turns) { time = 0; }
+    setPlaying(!playing);
+  });
+  document.getElementById("back").addEventListener("click", function () {
+    setPlaying(false); time = Math.max(0, Math.ceil(time) - 1); draw();
+  });
+  document.getElementById("forward").addEventListener("click", function () {
+    setPlaying(false); time = Math.min(data.turns, Math.floor(time) + 1); draw();
+  });
+  scrubber.addEventListener("input", function () {
+    setPlaying(false); time = parseFloat(scrubber.value); draw();
+  });
+  antPick.addEventListener("change", function () { select(parseInt(antPick.value, 10)); });
+
+  draw();
+  requestAnimationFrame(tick);
+})();
+</script>
+</body>
+</html>
+`
@@ -0,0 +1,130 @@
+package visualizer
+
+import (
+	"encoding/json"
+	"io"
+	"time"
+
+	"lem-in/structs"
+)
+
+// PhaseTiming is how long one phase of a run took.
+type PhaseTiming struct {
+	Phase    string
+	Duration time.Duration
+}
+
+type jsonRoom struct {
+	Name     string `json:"name"`
+	X        int    `json:"x"`
+	Y        int    `json:"y"`
+	Role     string `json:"role,omitempty"`
+	Capacity int    `json:"capacity,omitempty"`
+}
+
+type jsonTunnel struct {
+	From   string `json:"from"`
+	To     string `json:"to"`
+	Weight int    `json:"weight,omitempty"`
+}
+
+type jsonFarm struct {
+	Ants    int          `json:"ants"`
+	Start   string       `json:"start"`
+	End     string       `json:"end"`
+	Rooms   []jsonRoom   `json:"rooms"`
+	Tunnels []jsonTunnel `json:"tunnels"`
+}
+
+type jsonMove struct {
+	Ant  int    `json:"ant"`
+	From string `json:"from"`
+	To   string `json:"to"`
+}
+
+type jsonTiming struct {
+	Phase string  `json:"phase"`
+	Ms    float64 `json:"ms"`
+}
+
+type jsonBound struct {
+	ShortestPath int      `json:"shortestPath"`
+	CutSize      int      `json:"cutSize"`
+	Bottleneck   []string `json:"bottleneck"`
+	Turns        int      `json:"turns"`
+	Gap          int      `json:"gap"`
+}
+
+type jsonReport struct {
+	Farm           jsonFarm     `json:"farm"`
+	CandidateSets  [][][]string `json:"candidatePathSets"`
+	SelectedPaths  [][]string   `json:"selectedPaths"`
+	AntsPerPath    []int        `json:"antsPerPath"`
+	PredictedTurns int          `json:"predictedTurns"`
+	TotalTurns     int          `json:"totalTurns"`
+	LowerBound     jsonBound    `json:"lowerBound"`
+	Turns          [][]jsonMove `json:"turns"`
+	Replans        []string     `json:"replans,omitempty"`
+	Timings        []jsonTiming `json:"timings"`
+}
+
+// WriteJSON writes the full result of a run as one JSON document: the farm,
+// every candidate path set, the selected paths and their ant counts, the
+// moves of every turn, the re-plans of a run with events, the lower bound on
+// turns and the time spent in each phase.
+func WriteJSON(w io.Writer, farm *structs.Farm, candidates [][][]string,
+	assignment structs.PathAssignment, result structs.SimResult, bound structs.LowerBound, timings []PhaseTiming) error {
+	turns := result.Turns
+	report := jsonReport{
+		Farm: jsonFarm{
+			Ants:    farm.AntCount,
+			Start:   farm.Start,
+			End:     farm.End,
+			Rooms:   make([]jsonRoom, len(farm.Rooms)),
+			Tunnels: make([]jsonTunnel, len(farm.Tunnels)),
+		},
+		CandidateSets:  candidates,
+		SelectedPaths:  assignment.Paths,
+		AntsPerPath:    assignment.AntsPerPath,
+		PredictedTurns: assignment.PredictedTurns,
+		TotalTurns:     len(turns),
+		LowerBound: jsonBound{
+			ShortestPath: bound.ShortestPath,
+			CutSize:      bound.CutSize,
+			Bottleneck:   bound.Bottleneck,
+			Turns:        bound.Turns,
+			Gap:          len(turns) - bound.Turns,
+		},
+		Turns:   make([][]jsonMove, len(turns)),
+		Replans: result.Replans,
+		Timings: make([]jsonTiming, len(timings)),
+	}
+	for i, room := range farm.Rooms {
+		report.Farm.Rooms[i] = jsonRoom{Name: room.Name, X: room.X, Y: room.Y, Capacity: room.Capacity}
+		switch {
+		case room.IsStart:
+			report.Farm.Rooms[i].Role = "start"
+		case room.IsEnd:
+			report.Farm.Rooms[i].Role = "end"
+		}
+	}
+	for i, tunnel := range farm.Tunnels {
+		report.Farm.Tunnels[i] = jsonTunnel{From: tunnel.RoomA, To: tunnel.RoomB, Weight: tunnel.Weight}
+	}
+	for i, moves := range turns {
+		report.Turns[i] = make([]jsonMove, len(moves))
+		for j, move := range moves {
+			report.Turns[i][j] = jsonMove{Ant: move.Ant, From: move.From, To: move.To}
+		}
+	}
+	for i, timing := range timings {
+		report.Timings[i] = jsonTiming{Phase: timing.Phase, Ms: float64(timing.Duration.Microseconds()) / 1000}
+	}
+	if report.CandidateSets == nil {
+		report.CandidateSets = [][][]string{}
+	}
+
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(report)
+}
@@ -0,0 +1,145 @@
+package visualizer
+
+import (
+	"fmt"
+	"html"
+	"io"
+	"strings"
+
+	"lem-in/structs"
+)
+
+const (
+	svgMargin     = 60
+	svgCanvasSize = 800
+	svgRoomRadius = 12
+)
+
+// pathColors is the palette for selected paths; it wraps around when there
+// are more paths than colors.
+var pathColors = []string{
+	"#e6194b", "#3cb44b", "#4363d8", "#f58231", "#911eb4",
+	"#42d4f4", "#f032e6", "#bfef45", "#469990", "#9a6324",
+}
+
+// svgLayout maps room coordinates to pixel positions.
+type svgLayout struct {
+	points map[string][2]float64
+	width  float64
+	height float64
+}
+
+// newSVGLayout scales the farm so that its longest side spans the canvas.
+func newSVGLayout(rooms []structs.Room) *svgLayout {
+	minX, maxX, minY, maxY := 0, 0, 0, 0
+	for i, room := range rooms {
+		if i == 0 || room.X < minX {
+			minX = room.X
+		}
+		if i == 0 || room.X > maxX {
+			maxX = room.X
+		}
+		if i == 0 || room.Y < minY {
+			minY = room.Y
+		}
+		if i == 0 || room.Y > maxY {
+			maxY = room.Y
+		}
+	}
+	span := maxX - minX
+	if maxY-minY > span {
+		span = maxY - minY
+	}
+	scale := 1.0
+	if span > 0 {
+		scale = float64(svgCanvasSize) / float64(span)
+	}
+
+	layout := &svgLayout{
+		points: make(map[string][2]float64, len(rooms)),
+		width:  float64(maxX-minX)*scale + 2*svgMargin,
+		height: float64(maxY-minY)*scale + 2*svgMargin,
+	}
+	for _, room := range rooms {
+		layout.points[room.Name] = [2]float64{
+			float64(room.X-minX)*scale + svgMargin,
+			float64(room.Y-minY)*scale + svgMargin,
+		}
+	}
+	return layout
+}
+
+// pathColor returns the color of the i-th selected path.
+func pathColor(i int) string {
+	return pathColors[i%len(pathColors)]
+}
+
+// WriteSVG writes a standalone SVG picture of the farm: tunnels as lines,
+// rooms as circles at their coordinates, start and end highlighted, and each
+// selected path in its own color with the number of ants sent along it.
+func WriteSVG(w io.Writer, farm *structs.Farm, assignment structs.PathAssignment) error {
+	layout := newSVGLayout(farm.Rooms)
+	legendHeight := float64(20*len(assignment.Paths) + 20)
+
+	var b strings.Builder
+	fmt.Fprintf(&b, `<svg xmlns="http://www.w3.org/2000/svg" width="%.0f" height="%.0f" viewBox="0 0 %.0f %.0f" font-family="sans-serif" font-size="12">`+"\n",
+		layout.width, layout.height+legendHeight, layout.width, layout.height+legendHeight)
+	fmt.Fprintf(&b, `<rect width="100%%" height="100%%" fill="white"/>`+"\n")
+	writeSVGFarm(&b, farm, assignment, layout)
+
+	// legend
+	top := layout.height
+	for i, path := range assignment.Paths {
+		y := top + float64(20*i)
+		fmt.Fprintf(&b, `<line x1="10" y1="%.0f" x2="40" y2="%.0f" stroke="%s" stroke-width="4"/>`+"\n",
+			y, y, pathColor(i))
+		fmt.Fprintf(&b, `<text x="48" y="%.0f" dominant-baseline="middle">%s (%d ants)</text>`+"\n",
+			y, html.EscapeString(strings.Join(path, " → ")), assignment.AntsPerPath[i])
+	}
+	fmt.Fprintf(&b, `<text x="10" y="%.0f">%d ants, %d turns</text>`+"\n",
+		top+float64(20*len(assignment.Paths)), farm.AntCount, assignment.PredictedTurns)
+	b.WriteString("</svg>\n")
+
+	_, err := io.WriteString(w, b.String())
+	return err
+}
+
+// writeSVGFarm draws tunnels, selected paths and rooms.
+func writeSVGFarm(b *strings.Builder, farm *structs.Farm, assignment structs.PathAssignment, layout *svgLayout) {
+	b.WriteString(`<g id="tunnels" stroke="#bbbbbb" stroke-width="2">` + "\n")
+	for _, tunnel := range farm.Tunnels {
+		a, c := layout.points[tunnel.RoomA], layout.points[tunnel.RoomB]
+		fmt.Fprintf(b, `<line x1="%.1f" y1="%.1f" x2="%.1f" y2="%.1f"/>`+"\n", a[0], a[1], c[0], c[1])
+	}
+	b.WriteString("</g>\n")
+
+	b.WriteString(`<g id="paths" stroke-width="5" stroke-linecap="round" fill="none" opacity="0.8">` + "\n")
+	for i, path := range assignment.Paths {
+		points := make([]string, len(path))
+		for j, room := range path {
+			p := layout.points[room]
+			points[j] = fmt.Sprintf("%.1f,%.1f", p[0], p[1])
+		}
+		fmt.Fprintf(b, `<polyline stroke="%s" points="%s"><title>path %d: %d ants</title></polyline>`+"\n",
+			pathColor(i), strings.Join(points, " "), i+1, assignment.AntsPerPath[i])
+	}
+	b.WriteString("</g>\n")
+
+	b.WriteString(`<g id="rooms">` + "\n")
+	for _, room := range farm.Rooms {
+		p := layout.points[room.Name]
+		fill, radius := "#ffffff", svgRoomRadius
+		switch {
+		case room.IsStart:
+			fill, radius = "#8fd18f", svgRoomRadius+4
+		case room.IsEnd:
+			fill, radius = "#f08c8c", svgRoomRadius+4
+		}
+		name := html.EscapeString(room.Name)
+		fmt.Fprintf(b, `<circle id="room-%s" cx="%.1f" cy="%.1f" r="%d" fill="%s" stroke="#333333" stroke-width="2"/>`+"\n",
+			name, p[0], p[1], radius, fill)
+		fmt.Fprintf(b, `<text x="%.1f" y="%.1f" text-anchor="middle">%s</text>`+"\n",
+			p[0], p[1]-float64(radius)-4, name)
+	}
+	b.WriteString("</g>\n")
+}
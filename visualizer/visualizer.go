@@ -2,6 +2,7 @@ package visualizer
 
 import (
 	"fmt"
+	"io"
 	"os"
 	"strings"
 
@@ -18,14 +19,29 @@ func buildRawInput(antTotal int, roomList []structs.Room, tunnelList []structs.T
 		if room.IsEnd {
 			builder.WriteString("##end\n")
 		}
+		if room.Capacity > 0 {
+			builder.WriteString(fmt.Sprintf("##capacity %d\n", room.Capacity))
+		}
 		builder.WriteString(fmt.Sprintf("%s %d %d\n", room.Name, room.X, room.Y))
 	}
 	for _, tunnel := range tunnelList {
-		builder.WriteString(fmt.Sprintf("%s-%s\n", tunnel.RoomA, tunnel.RoomB))
+		if tunnel.Weight > 0 {
+			builder.WriteString(fmt.Sprintf("%s-%s %d\n", tunnel.RoomA, tunnel.RoomB, tunnel.Weight))
+		} else {
+			builder.WriteString(fmt.Sprintf("%s-%s\n", tunnel.RoomA, tunnel.RoomB))
+		}
 	}
 	return builder.String()
 }
 
+// WriteFarm writes the farm in the input text format: the ant count, the
+// rooms with their ##start, ##end and ##capacity directives, then the
+// tunnels with their weights.
+func WriteFarm(w io.Writer, farm *structs.Farm) error {
+	_, err := io.WriteString(w, buildRawInput(farm.AntCount, farm.Rooms, farm.Tunnels))
+	return err
+}
+
 func buildSummary(antTotal int, roomList []structs.Room, tunnelList []structs.Tunnel) string {
 	var builder strings.Builder
 	builder.WriteString("----------- Summary -----------\n")
@@ -80,23 +96,54 @@ func PrintExtraInfo(antTotal int, roomList []structs.Room, tunnelList []structs.
 	return builder.String()
 }
 
-// GeneratePathGrid renders one path, marking any ants present.
+// PrintReplanInfo builds the header of a run with events: input, summary and
+// the re-plans made during the run in place of the path info.
+func PrintReplanInfo(antTotal int, roomList []structs.Room, tunnelList []structs.Tunnel,
+	replans []string) string {
+	var builder strings.Builder
+	builder.WriteString(buildRawInput(antTotal, roomList, tunnelList))
+	builder.WriteString("\n")
+	builder.WriteString(buildSummary(antTotal, roomList, tunnelList))
+	builder.WriteString("\n")
+	builder.WriteString("---------- Re-plans ----------\n")
+	builder.WriteString("Paths were planned again during the run; the lower bound is for the farm\n")
+	builder.WriteString("with every room and tunnel open.\n")
+	for _, entry := range replans {
+		builder.WriteString(entry + "\n")
+	}
+	builder.WriteString("\n")
+	return builder.String()
+}
+
+// GeneratePathGrid renders one path, marking any ants present. Ants inside
+// a tunnel are shown on the arrow leading to their next room.
 func GeneratePathGrid(sim structs.PathSim) string {
 	var builder strings.Builder
 	for i, room := range sim.Path {
-		var antLabels []string
+		var antLabels, transitLabels []string
 		for j, pos := range sim.Positions {
-			if pos == i {
-				antLabels = append(antLabels, fmt.Sprintf("L%d", sim.AntIDs[j]))
+			if pos != i {
+				continue
+			}
+			label := fmt.Sprintf("L%d", sim.AntIDs[j])
+			if j < len(sim.Transit) && sim.Transit[j] > 0 {
+				transitLabels = append(transitLabels, label)
+			} else {
+				antLabels = append(antLabels, label)
 			}
 		}
+		if len(transitLabels) > 0 {
+			builder.WriteString(fmt.Sprintf("-(%s)-> ", strings.Join(transitLabels, ", ")))
+		} else if i > 0 {
+			builder.WriteString("---> ")
+		}
 		if len(antLabels) > 0 {
 			builder.WriteString(fmt.Sprintf("[ %s (%s) ]", room, strings.Join(antLabels, ", ")))
 		} else {
 			builder.WriteString(fmt.Sprintf("[ %s ]", room))
 		}
 		if i < len(sim.Path)-1 {
-			builder.WriteString(" ---> ")
+			builder.WriteString(" ")
 		}
 	}
 	return builder.String()
@@ -105,6 +152,12 @@ func GeneratePathGrid(sim structs.PathSim) string {
 // WriteSimulationOutput writes the full simulation grid to a file.
 func WriteSimulationOutput(filename string, headerInfo string,
 	turnGrids []string, totalTurns int) error {
+	report := BuildSimulationReport(headerInfo, turnGrids, totalTurns)
+	return os.WriteFile(filename, []byte(report), 0644)
+}
+
+// BuildSimulationReport builds the header followed by the grid of every turn.
+func BuildSimulationReport(headerInfo string, turnGrids []string, totalTurns int) string {
 	var builder strings.Builder
 	builder.WriteString(headerInfo)
 	builder.WriteString("\n\n")
@@ -114,12 +167,68 @@ func WriteSimulationOutput(filename string, headerInfo string,
 		builder.WriteString("\n")
 	}
 	builder.WriteString(fmt.Sprintf("Total turns: %d\n", totalTurns))
-	return os.WriteFile(filename, []byte(builder.String()), 0644)
+	return builder.String()
 }
 
-// PrintTerminalOutput prints concise move info per turn.
-func PrintTerminalOutput(moveList []string) {
+// WriteTerminalOutput writes concise move info per turn to w.
+func WriteTerminalOutput(w io.Writer, moveList []string) {
 	for i, moves := range moveList {
-		fmt.Printf("Turn %d: %s\n", i+1, moves)
+		fmt.Fprintf(w, "Turn %d: %s\n", i+1, moves)
+	}
+}
+
+// FormatMoves joins one turn of moves into a single "Lx-room Ly-room" line.
+func FormatMoves(moves []structs.Move) string {
+	parts := make([]string, len(moves))
+	for i, move := range moves {
+		parts[i] = move.String()
+	}
+	return strings.Join(parts, " ")
+}
+
+// WriteSubjectOutput writes the subject format to w: the input lines as
+// read, a blank line, then one line of moves per turn.
+func WriteSubjectOutput(w io.Writer, inputLines []string, turns [][]structs.Move) {
+	for _, line := range inputLines {
+		fmt.Fprintln(w, line)
+	}
+	fmt.Fprintln(w)
+	for _, moves := range turns {
+		fmt.Fprintln(w, FormatMoves(moves))
 	}
 }
+
+// BuildStats summarizes a solved farm: counts, the selected paths with the
+// ants sent down each one, and the predicted number of turns.
+func BuildStats(antTotal int, roomList []structs.Room, tunnelList []structs.Tunnel,
+	candidateCount int, assignment structs.PathAssignment) string {
+	var builder strings.Builder
+	builder.WriteString(buildSummary(antTotal, roomList, tunnelList))
+	builder.WriteString(fmt.Sprintf("Candidate path sets: %d\n", candidateCount))
+	builder.WriteString("\n")
+	builder.WriteString("---------- Selected Paths ----------\n")
+	for i, path := range assignment.Paths {
+		builder.WriteString(fmt.Sprintf("%d) %s (%d ants)\n",
+			i+1, strings.Join(path, " -> "), assignment.AntsPerPath[i]))
+	}
+	builder.WriteString("\n")
+	builder.WriteString(fmt.Sprintf("Predicted turns: %d\n", assignment.PredictedTurns))
+	return builder.String()
+}
+
+// BuildOptimality compares the achieved number of turns with the lower bound.
+func BuildOptimality(antTotal int, bound structs.LowerBound, turns int) string {
+	var builder strings.Builder
+	builder.WriteString("---------- Optimality ----------\n")
+	builder.WriteString(fmt.Sprintf("Shortest path: %d turns\n", bound.ShortestPath))
+	builder.WriteString(fmt.Sprintf("Min cut: %d (bottleneck: %s)\n", bound.CutSize, strings.Join(bound.Bottleneck, ", ")))
+	builder.WriteString(fmt.Sprintf("Lower bound: %d + ceil(%d/%d) - 1 = %d turns\n",
+		bound.ShortestPath, antTotal, bound.CutSize, bound.Turns))
+	gap := turns - bound.Turns
+	if gap == 0 {
+		builder.WriteString(fmt.Sprintf("Achieved: %d turns (optimal)\n", turns))
+	} else {
+		builder.WriteString(fmt.Sprintf("Achieved: %d turns (gap %d)\n", turns, gap))
+	}
+	return builder.String()
+}